@@ -0,0 +1,254 @@
+// Package api is a client for the FeaturePeek API
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"peek/upload"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// DefaultTimeout bounds API calls other than uploads, which may take as long as the transfer needs
+const DefaultTimeout = 30 * time.Second
+
+// TokenSource hands out the access token sent with each request
+type TokenSource interface {
+	AccessToken() (string, error)
+}
+
+// StaticToken is a TokenSource that always returns the same token
+type StaticToken string
+
+// AccessToken returns the token
+func (t StaticToken) AccessToken() (string, error) {
+	return string(t), nil
+}
+
+// Client sends authenticated requests to the FeaturePeek API
+type Client struct {
+	// BaseURL is the API endpoint, such as https://api.featurepeek.com
+	BaseURL string
+	Tokens  TokenSource
+	// Version is the CLI version, sent in the X-FEATUREPEEK-CLIENT header and the user agent
+	Version string
+	HTTP    *http.Client
+	// Timeout bounds each API call except uploads; zero uses DefaultTimeout
+	Timeout time.Duration
+	// Retry applies to requests whose body can be sent again
+	Retry upload.Retry
+	// Debug receives a line for each request and response when set
+	Debug io.Writer
+}
+
+// Response is a successful API response
+type Response struct {
+	StatusCode int
+	Body       []byte
+}
+
+// APIError is an unsuccessful API response. Errors holds the messages from
+// the API's {"errors": [...]} payload, when it sent one.
+type APIError struct {
+	StatusCode int
+	Errors     []string
+	Body       []byte
+}
+
+func (e *APIError) Error() string {
+	switch {
+	case len(e.Errors) > 0:
+		return fmt.Sprintf("request failed with status %d - %s", e.StatusCode, strings.Join(e.Errors, "; "))
+	case len(bytes.TrimSpace(e.Body)) > 0:
+		return fmt.Sprintf("request failed with status %d - %s", e.StatusCode, bytes.TrimSpace(e.Body))
+	}
+	return fmt.Sprintf("request failed with status %d", e.StatusCode)
+}
+
+// Unwrap exposes the response status as an *upload.StatusError, so retries treat
+// API errors like any other failed request
+func (e *APIError) Unwrap() error {
+	return &upload.StatusError{StatusCode: e.StatusCode, Body: e.Body}
+}
+
+// NewAPIError builds an APIError from a response status and body
+func NewAPIError(statusCode int, body []byte) *APIError {
+	apiErr := &APIError{StatusCode: statusCode, Body: body}
+	var payload struct {
+		Errors []string
+	}
+	if json.Unmarshal(body, &payload) == nil {
+		apiErr.Errors = payload.Errors
+	}
+	return apiErr
+}
+
+// UserAgent returns the User-Agent header sent with each request
+func (c *Client) UserAgent() string {
+	return fmt.Sprintf("peek/%s (%s/%s)", c.Version, runtime.GOOS, runtime.GOARCH)
+}
+
+// URL returns the full URL of an API path
+func (c *Client) URL(path string) string {
+	return strings.TrimSuffix(c.BaseURL, "/") + "/" + strings.TrimPrefix(path, "/")
+}
+
+// Header returns the authentication and client headers sent with each request
+func (c *Client) Header() (http.Header, error) {
+	header := http.Header{}
+	header.Set("User-Agent", c.UserAgent())
+	header.Set("X-FEATUREPEEK-CLIENT", c.Version)
+	if c.Tokens != nil {
+		token, err := c.Tokens.AccessToken()
+		if err != nil {
+			return nil, err
+		}
+		header.Set("Authorization", "Bearer "+token)
+	}
+	return header, nil
+}
+
+// defaultHTTPClient is used by clients without their own HTTP client
+var defaultHTTPClient, _ = NewHTTPClient(HTTPOptions{})
+
+// HTTPClient returns the underlying HTTP client
+func (c *Client) HTTPClient() *http.Client {
+	if c.HTTP == nil {
+		return defaultHTTPClient
+	}
+	return c.HTTP
+}
+
+// NewRequest builds an authenticated request for an API path
+func (c *Client) NewRequest(method, path string, body io.Reader) (*http.Request, error) {
+	request, err := http.NewRequest(method, c.URL(path), body)
+	if err != nil {
+		return nil, err
+	}
+	header, err := c.Header()
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range header {
+		request.Header[k] = v
+	}
+	return request, nil
+}
+
+// Post sends a JSON body (or none, if body is nil) to an API path
+func (c *Client) Post(path string, body interface{}) (*Response, error) {
+	var data []byte
+	if body != nil {
+		var err error
+		if data, err = json.Marshal(body); err != nil {
+			return nil, err
+		}
+	}
+
+	request, err := c.NewRequest("POST", path, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		request.Header.Set("Content-Type", "application/json")
+	}
+	return c.Do(request)
+}
+
+// RegisterUser checks the logged in user in with the API, creating their FeaturePeek user if needed
+func (c *Client) RegisterUser() error {
+	_, err := c.Post("/api/v1/user", nil)
+	return err
+}
+
+// UploadForm posts a multipart form to an API path, streaming the archive as it
+// is packaged. With contentLength the form is packaged once first to measure it.
+// Streamed uploads are not retried and have no overall timeout.
+func (c *Client) UploadForm(path string, form *upload.Form, contentLength bool) (*Response, error) {
+	var size int64
+	if contentLength {
+		var err error
+		if size, err = form.Size(); err != nil {
+			return nil, fmt.Errorf("Error packaging assets: %v", err)
+		}
+	}
+
+	request, err := c.NewRequest("POST", path, form.Reader())
+	if err != nil {
+		return nil, err
+	}
+	request.ContentLength = size
+	request.Header.Set("Content-Type", form.ContentType())
+	return c.send(request)
+}
+
+// Do sends a request, retrying transient failures when the body can be sent
+// again. Responses outside 2xx are returned as an *APIError.
+func (c *Client) Do(request *http.Request) (*Response, error) {
+	timeout := c.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+
+	if request.Body != nil && request.Body != http.NoBody && request.GetBody == nil {
+		ctx, cancel := context.WithTimeout(request.Context(), timeout)
+		defer cancel()
+		return c.send(request.WithContext(ctx))
+	}
+
+	var response *Response
+	err := c.Retry.Do(func() error {
+		attempt := request.Clone(request.Context())
+		if request.GetBody != nil {
+			body, err := request.GetBody()
+			if err != nil {
+				return err
+			}
+			attempt.Body = body
+		}
+
+		ctx, cancel := context.WithTimeout(attempt.Context(), timeout)
+		defer cancel()
+
+		var err error
+		response, err = c.send(attempt.WithContext(ctx))
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// send performs a single request
+func (c *Client) send(request *http.Request) (*Response, error) {
+	if c.Debug != nil {
+		fmt.Fprintf(c.Debug, "-> %s %s\n", request.Method, request.URL)
+	}
+
+	res, err := c.HTTPClient().Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.Debug != nil {
+		fmt.Fprintf(c.Debug, "<- %d %s\n", res.StatusCode, body)
+	}
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return nil, NewAPIError(res.StatusCode, body)
+	}
+	return &Response{StatusCode: res.StatusCode, Body: body}, nil
+}
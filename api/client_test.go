@@ -0,0 +1,182 @@
+package api
+
+import (
+	"errors"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"peek/upload"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newTestClient(url string) *Client {
+	return &Client{
+		BaseURL: url + "/",
+		Tokens:  StaticToken("secret"),
+		Version: "1.2.3",
+		Retry:   upload.Retry{Attempts: 3, Initial: time.Millisecond},
+	}
+}
+
+func TestClient_RegisterUser(t *testing.T) {
+	var got *http.Request
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer server.Close()
+
+	err := newTestClient(server.URL).RegisterUser()
+	eq(t, err, nil)
+	eq(t, got.Method, "POST")
+	eq(t, got.URL.Path, "/api/v1/user")
+	eq(t, got.Header.Get("Authorization"), "Bearer secret")
+	eq(t, got.Header.Get("X-FEATUREPEEK-CLIENT"), "1.2.3")
+	if !strings.HasPrefix(got.Header.Get("User-Agent"), "peek/1.2.3 (") {
+		t.Errorf("unexpected user agent %q", got.Header.Get("User-Agent"))
+	}
+}
+
+func TestClient_APIError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnprocessableEntity)
+		w.Write([]byte(`{"errors":["sha not found on remote","branch is required"]}`))
+	}))
+	defer server.Close()
+
+	_, err := newTestClient(server.URL).Post("/api/v1/user", map[string]string{"a": "b"})
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		t.Fatalf("expected *APIError, got %v", err)
+	}
+	eq(t, apiErr.StatusCode, http.StatusUnprocessableEntity)
+	eq(t, apiErr.Errors, []string{"sha not found on remote", "branch is required"})
+	eq(t, err.Error(), "request failed with status 422 - sha not found on remote; branch is required")
+}
+
+func TestClient_APIErrorWithoutPayload(t *testing.T) {
+	eq(t, NewAPIError(http.StatusBadGateway, []byte("<html>bad gateway</html>\n")).Error(),
+		"request failed with status 502 - <html>bad gateway</html>")
+	eq(t, NewAPIError(http.StatusNotFound, nil).Error(), "request failed with status 404")
+}
+
+func TestClient_RetriesServerErrors(t *testing.T) {
+	var bodies []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+		if len(bodies) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	res, err := newTestClient(server.URL).Post("/things", map[string]int{"n": 1})
+	eq(t, err, nil)
+	eq(t, string(res.Body), "ok")
+	eq(t, bodies, []string{`{"n":1}`, `{"n":1}`, `{"n":1}`})
+}
+
+func TestClient_DoesNotRetryClientErrors(t *testing.T) {
+	requests := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		w.WriteHeader(http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	_, err := newTestClient(server.URL).Post("/things", nil)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	eq(t, requests, 1)
+}
+
+func TestClient_Timeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	client := newTestClient(server.URL)
+	client.Timeout = 20 * time.Millisecond
+	client.Retry = upload.Retry{}
+	if _, err := client.Post("/slow", nil); err == nil {
+		t.Error("expected the request to time out")
+	}
+}
+
+func TestClient_TokenError(t *testing.T) {
+	client := newTestClient("http://127.0.0.1:0")
+	client.Tokens = failingTokens{}
+	_, err := client.Post("/things", nil)
+	eq(t, err.Error(), "no token")
+}
+
+func TestClient_UploadForm(t *testing.T) {
+	dir := t.TempDir()
+	if err := ioutil.WriteFile(filepath.Join(dir, "index.html"), []byte("<h1>hi</h1>"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	form := upload.NewForm(dir)
+	form.AddField("app", "main")
+
+	var contentLength int64
+	var fields map[string]string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		contentLength = r.ContentLength
+		_, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader := multipart.NewReader(r.Body, params["boundary"])
+		fields = make(map[string]string)
+		for {
+			part, err := reader.NextPart()
+			if err != nil {
+				break
+			}
+			data, _ := ioutil.ReadAll(part)
+			if part.FileName() == "" {
+				fields[part.FormName()] = string(data)
+			} else {
+				fields[part.FormName()] = part.FileName()
+			}
+		}
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("https://preview.example.com"))
+	}))
+	defer server.Close()
+
+	size, err := form.Size()
+	eq(t, err, nil)
+
+	res, err := newTestClient(server.URL).UploadForm("/api/v1/peek", form, true)
+	eq(t, err, nil)
+	eq(t, res.StatusCode, http.StatusCreated)
+	eq(t, string(res.Body), "https://preview.example.com")
+	eq(t, contentLength, size)
+	eq(t, fields, map[string]string{"app": "main", "artifacts": "artifacts.tar.gz"})
+}
+
+// Helper functions
+type failingTokens struct{}
+
+func (failingTokens) AccessToken() (string, error) {
+	return "", errors.New("no token")
+}
+
+func eq(t *testing.T, got interface{}, expected interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected: %v, got: %v", expected, got)
+	}
+}
@@ -0,0 +1,95 @@
+package api
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// HTTPOptions holds the proxy and TLS settings for reaching FeaturePeek and
+// Auth0 from networks with an intercepting proxy or private CA
+type HTTPOptions struct {
+	// Proxy is the proxy URL; empty uses HTTPS_PROXY, HTTP_PROXY and NO_PROXY
+	Proxy string
+	// CABundle is a PEM file of certificates trusted in addition to the system roots
+	CABundle string
+	// ClientCert and ClientKey are PEM files presented for mutual TLS
+	ClientCert string
+	ClientKey  string
+	// InsecureSkipVerify disables server certificate checks, for local stand-ins only
+	InsecureSkipVerify bool
+}
+
+// NewHTTPClient returns an HTTP client with connection timeouts suitable for
+// long uploads. It has no overall timeout; Client bounds API calls itself.
+func NewHTTPClient(opts HTTPOptions) (*http.Client, error) {
+	proxy := http.ProxyFromEnvironment
+	if opts.Proxy != "" {
+		proxyURL, err := url.Parse(opts.Proxy)
+		if err != nil || proxyURL.Scheme == "" || proxyURL.Host == "" {
+			return nil, fmt.Errorf("invalid proxy URL %q", opts.Proxy)
+		}
+		proxy = http.ProxyURL(proxyURL)
+	}
+
+	tlsConfig, err := opts.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	return &http.Client{
+		Transport: &http.Transport{
+			Proxy: proxy,
+			DialContext: (&net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			TLSClientConfig:       tlsConfig,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: 5 * time.Minute,
+			ExpectContinueTimeout: time.Second,
+			IdleConnTimeout:       90 * time.Second,
+			MaxIdleConns:          10,
+		},
+	}, nil
+}
+
+func (opts HTTPOptions) tlsConfig() (*tls.Config, error) {
+	config := &tls.Config{
+		InsecureSkipVerify: opts.InsecureSkipVerify,
+	}
+
+	if opts.CABundle != "" {
+		pem, err := ioutil.ReadFile(opts.CABundle)
+		if err != nil {
+			return nil, fmt.Errorf("could not read CA bundle: %v", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in CA bundle %s", opts.CABundle)
+		}
+		config.RootCAs = pool
+	}
+
+	if opts.ClientCert != "" || opts.ClientKey != "" {
+		if opts.ClientCert == "" || opts.ClientKey == "" {
+			return nil, errors.New("a client certificate and key must be given together")
+		}
+		cert, err := tls.LoadX509KeyPair(opts.ClientCert, opts.ClientKey)
+		if err != nil {
+			return nil, fmt.Errorf("could not load client certificate: %v", err)
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+
+	return config, nil
+}
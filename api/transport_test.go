@@ -0,0 +1,160 @@
+package api
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestNewHTTPClient_CABundle(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	client, err := NewHTTPClient(HTTPOptions{})
+	eq(t, err, nil)
+	if _, err = client.Get(server.URL); err == nil {
+		t.Error("expected the self-signed certificate to be rejected")
+	}
+
+	bundle := writePEM(t, "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+	client, err = NewHTTPClient(HTTPOptions{CABundle: bundle})
+	eq(t, err, nil)
+	res, err := client.Get(server.URL)
+	eq(t, err, nil)
+	eq(t, res.StatusCode, http.StatusOK)
+}
+
+func TestNewHTTPClient_InsecureSkipVerify(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	client, err := NewHTTPClient(HTTPOptions{InsecureSkipVerify: true})
+	eq(t, err, nil)
+	res, err := client.Get(server.URL)
+	eq(t, err, nil)
+	eq(t, res.StatusCode, http.StatusOK)
+}
+
+func TestNewHTTPClient_ClientCertificate(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	certDER, keyDER := newClientCertificate(t)
+	cert, err := x509.ParseCertificate(certDER)
+	eq(t, err, nil)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(cert)
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+
+	bundle := writePEM(t, "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+	client, err := NewHTTPClient(HTTPOptions{CABundle: bundle})
+	eq(t, err, nil)
+	if _, err = client.Get(server.URL); err == nil {
+		t.Error("expected the server to require a client certificate")
+	}
+
+	client, err = NewHTTPClient(HTTPOptions{
+		CABundle:   bundle,
+		ClientCert: writePEM(t, "client.pem", "CERTIFICATE", certDER),
+		ClientKey:  writePEM(t, "client-key.pem", "EC PRIVATE KEY", keyDER),
+	})
+	eq(t, err, nil)
+	res, err := client.Get(server.URL)
+	eq(t, err, nil)
+	defer res.Body.Close()
+	body, _ := ioutil.ReadAll(res.Body)
+	eq(t, string(body), "peek-test-client")
+}
+
+func TestNewHTTPClient_Proxy(t *testing.T) {
+	var requested string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requested = r.URL.String()
+	}))
+	defer proxy.Close()
+
+	client, err := NewHTTPClient(HTTPOptions{Proxy: proxy.URL})
+	eq(t, err, nil)
+	res, err := client.Get("http://api.example.com/api/v1/user")
+	eq(t, err, nil)
+	eq(t, res.StatusCode, http.StatusOK)
+	eq(t, requested, "http://api.example.com/api/v1/user")
+}
+
+func TestNewHTTPClient_InvalidOptions(t *testing.T) {
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "ca.pem")
+	if err := ioutil.WriteFile(notPEM, []byte("not a certificate"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		opts HTTPOptions
+		err  string
+	}{
+		{"proxy without scheme", HTTPOptions{Proxy: "proxy.example.com:8080"}, `invalid proxy URL "proxy.example.com:8080"`},
+		{"empty CA bundle", HTTPOptions{CABundle: notPEM}, "no certificates found in CA bundle " + notPEM},
+		{"cert without key", HTTPOptions{ClientCert: notPEM}, "a client certificate and key must be given together"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := NewHTTPClient(tt.opts)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			eq(t, err.Error(), tt.err)
+		})
+	}
+}
+
+// Helper functions
+func newClientCertificate(t *testing.T) ([]byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "peek-test-client"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return certDER, keyDER
+}
+
+func writePEM(t *testing.T, name, blockType string, der []byte) string {
+	t.Helper()
+	filename := filepath.Join(t.TempDir(), name)
+	data := pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der})
+	if err := ioutil.WriteFile(filename, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return filename
+}
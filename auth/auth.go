@@ -1,5 +1,12 @@
 package auth
 
+import (
+	"encoding/json"
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+)
+
 // Auth respresents the json auth structure returned from Auth0 which is also stored locally on login
 type Auth struct {
 	AccessToken  string `json:"access_token"`
@@ -7,4 +14,43 @@ type Auth struct {
 	IDToken      string `json:"id_token"`
 	TokenType    string `json:"token_type"`
 	ExpiresIn    int    `json:"expires_in"`
+	// IssuedAt is the unix time the tokens were received; it is not part of the Auth0 response
+	IssuedAt int64 `json:"issued_at,omitempty"`
+}
+
+// ExpiresAt returns when the access token expires. Tokens saved without an
+// issue time fall back to the token's exp claim; the zero time means unknown.
+func (a Auth) ExpiresAt() time.Time {
+	if a.IssuedAt > 0 && a.ExpiresIn > 0 {
+		return time.Unix(a.IssuedAt+int64(a.ExpiresIn), 0)
+	}
+	if exp := tokenExpiry(a.AccessToken); exp > 0 {
+		return time.Unix(exp, 0)
+	}
+	return time.Time{}
+}
+
+// Expired reports whether the access token expires within leeway of now.
+// A token with an unknown expiry is never considered expired.
+func (a Auth) Expired(now time.Time, leeway time.Duration) bool {
+	expiresAt := a.ExpiresAt()
+	if expiresAt.IsZero() {
+		return false
+	}
+	return !now.Add(leeway).Before(expiresAt)
+}
+
+// tokenExpiry reads the exp claim of a JWT without verifying it
+func tokenExpiry(token string) int64 {
+	object, err := jose.ParseSigned(token)
+	if err != nil {
+		return 0
+	}
+	var claims struct {
+		Exp int64 `json:"exp"`
+	}
+	if err = json.Unmarshal(object.UnsafePayloadWithoutVerification(), &claims); err != nil {
+		return 0
+	}
+	return claims.Exp
 }
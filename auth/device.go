@@ -0,0 +1,196 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const deviceCodeGrantType = "urn:ietf:params:oauth:grant-type:device_code"
+
+// defaultPollInterval is the polling interval used when the server does not give one (RFC 8628 section 3.2)
+const defaultPollInterval = 5 * time.Second
+
+// slowDownIncrement is added to the polling interval on each slow_down response (RFC 8628 section 3.5)
+const slowDownIncrement = 5 * time.Second
+
+// ErrAccessDenied is returned when the user declines the device authorization request
+var ErrAccessDenied = errors.New("login request was denied")
+
+// ErrDeviceCodeExpired is returned when the device code expires before the user approves it
+var ErrDeviceCodeExpired = errors.New("login request expired before it was approved; run `peek login` to try again")
+
+// OAuthError is an error response from the Auth0 token or device code endpoints
+type OAuthError struct {
+	StatusCode  int
+	Code        string `json:"error"`
+	Description string `json:"error_description"`
+}
+
+func (e *OAuthError) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("auth request failed with status %d", e.StatusCode)
+	}
+	if e.Description == "" {
+		return e.Code
+	}
+	return fmt.Sprintf("%s: %s", e.Code, e.Description)
+}
+
+// DeviceCode is the response that starts a device authorization flow
+type DeviceCode struct {
+	DeviceCode              string `json:"device_code"`
+	UserCode                string `json:"user_code"`
+	VerificationURI         string `json:"verification_uri"`
+	VerificationURIComplete string `json:"verification_uri_complete"`
+	// ExpiresIn and Interval are in seconds
+	ExpiresIn int `json:"expires_in"`
+	Interval  int `json:"interval"`
+}
+
+// DeviceFlow runs the OAuth 2.0 device authorization grant (RFC 8628) against an Auth0 tenant
+type DeviceFlow struct {
+	BaseURL  string
+	ClientID string
+	Client   *http.Client
+	Now      func() time.Time
+	// wait blocks for d or until ctx is done; it is replaced in tests
+	wait func(ctx context.Context, d time.Duration) error
+}
+
+// RequestCode asks for a device code and the user code to show the user
+func (f *DeviceFlow) RequestCode(ctx context.Context, audience, scope string) (*DeviceCode, error) {
+	data := url.Values{}
+	data.Set("audience", audience)
+	data.Set("scope", scope)
+
+	body, err := f.post(ctx, "/oauth/device/code", data)
+	if err != nil {
+		return nil, err
+	}
+
+	var code DeviceCode
+	if err = json.Unmarshal(body, &code); err != nil {
+		return nil, err
+	}
+	return &code, nil
+}
+
+// PollToken polls the token endpoint until the user approves or denies the
+// request, the device code expires, or ctx is cancelled. The polling interval
+// grows on each slow_down response.
+func (f *DeviceFlow) PollToken(ctx context.Context, code *DeviceCode) (*Auth, error) {
+	interval := time.Duration(code.Interval) * time.Second
+	if interval <= 0 {
+		interval = defaultPollInterval
+	}
+
+	var deadline time.Time
+	if code.ExpiresIn > 0 {
+		deadline = f.now().Add(time.Duration(code.ExpiresIn) * time.Second)
+	}
+
+	data := url.Values{}
+	data.Set("grant_type", deviceCodeGrantType)
+	data.Set("device_code", code.DeviceCode)
+
+	for {
+		if !deadline.IsZero() && !f.now().Add(interval).Before(deadline) {
+			return nil, ErrDeviceCodeExpired
+		}
+		if err := f.sleep(ctx, interval); err != nil {
+			return nil, err
+		}
+
+		body, err := f.post(ctx, "/oauth/token", data)
+		if err == nil {
+			var tokens Auth
+			if err = json.Unmarshal(body, &tokens); err != nil {
+				return nil, err
+			}
+			return &tokens, nil
+		}
+
+		var oauthErr *OAuthError
+		if !errors.As(err, &oauthErr) {
+			return nil, err
+		}
+		switch oauthErr.Code {
+		case "authorization_pending":
+		case "slow_down":
+			interval += slowDownIncrement
+		case "expired_token":
+			return nil, ErrDeviceCodeExpired
+		case "access_denied":
+			return nil, ErrAccessDenied
+		default:
+			return nil, err
+		}
+	}
+}
+
+func (f *DeviceFlow) now() time.Time {
+	if f.Now != nil {
+		return f.Now()
+	}
+	return time.Now()
+}
+
+func (f *DeviceFlow) sleep(ctx context.Context, d time.Duration) error {
+	if f.wait != nil {
+		return f.wait(ctx, d)
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+func (f *DeviceFlow) post(ctx context.Context, path string, data url.Values) ([]byte, error) {
+	return postForm(ctx, f.Client, f.BaseURL, f.ClientID, path, data)
+}
+
+// postForm sends a form to the Auth0 tenant at baseURL, returning an
+// *OAuthError for non-200 responses
+func postForm(ctx context.Context, client *http.Client, baseURL, clientID, path string, data url.Values) ([]byte, error) {
+	data.Set("client_id", clientID)
+
+	req, err := http.NewRequest("POST", strings.TrimSuffix(baseURL, "/")+path, strings.NewReader(data.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	body, err := ioutil.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		oauthErr := &OAuthError{StatusCode: res.StatusCode}
+		json.Unmarshal(body, oauthErr)
+		return nil, oauthErr
+	}
+	return body, nil
+}
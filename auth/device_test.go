@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// fakeAuth0 serves the device code and token endpoints, answering token polls with
+// the given error codes in order and then with tokens
+func fakeAuth0(t *testing.T, code DeviceCode, pollErrors []string) (*httptest.Server, *int) {
+	t.Helper()
+	polls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		eq(t, r.PostForm.Get("client_id"), "client-id")
+
+		switch r.URL.Path {
+		case "/oauth/device/code":
+			eq(t, r.PostForm.Get("audience"), "http://api.example.com/")
+			json.NewEncoder(w).Encode(code)
+		case "/oauth/token":
+			eq(t, r.PostForm.Get("grant_type"), deviceCodeGrantType)
+			eq(t, r.PostForm.Get("device_code"), code.DeviceCode)
+			polls++
+			if polls <= len(pollErrors) {
+				w.WriteHeader(http.StatusForbidden)
+				json.NewEncoder(w).Encode(map[string]string{
+					"error":             pollErrors[polls-1],
+					"error_description": "description of " + pollErrors[polls-1],
+				})
+				return
+			}
+			json.NewEncoder(w).Encode(Auth{AccessToken: "access", RefreshToken: "refresh", ExpiresIn: 86400})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &polls
+}
+
+// stubDeviceFlow returns a flow whose clock advances by each wait instead of sleeping
+func stubDeviceFlow(baseURL string, waits *[]time.Duration) *DeviceFlow {
+	now := time.Unix(1000, 0)
+	return &DeviceFlow{
+		BaseURL:  baseURL,
+		ClientID: "client-id",
+		Now:      func() time.Time { return now },
+		wait: func(ctx context.Context, d time.Duration) error {
+			*waits = append(*waits, d)
+			now = now.Add(d)
+			return ctx.Err()
+		},
+	}
+}
+
+func TestDeviceFlow_RequestCode(t *testing.T) {
+	want := DeviceCode{DeviceCode: "dev-code", UserCode: "ABCD-EFGH", VerificationURI: "https://example.com/activate", ExpiresIn: 900, Interval: 5}
+	server, _ := fakeAuth0(t, want, nil)
+
+	var waits []time.Duration
+	code, err := stubDeviceFlow(server.URL, &waits).RequestCode(context.Background(), "http://api.example.com/", "offline_access")
+	eq(t, err, nil)
+	eq(t, *code, want)
+}
+
+func TestDeviceFlow_PollSlowDown(t *testing.T) {
+	code := DeviceCode{DeviceCode: "dev-code", ExpiresIn: 900, Interval: 2}
+	server, polls := fakeAuth0(t, code, []string{"authorization_pending", "slow_down", "authorization_pending", "slow_down"})
+
+	var waits []time.Duration
+	tokens, err := stubDeviceFlow(server.URL, &waits).PollToken(context.Background(), &code)
+	eq(t, err, nil)
+	eq(t, tokens.AccessToken, "access")
+	eq(t, *polls, 5)
+
+	want := []time.Duration{2 * time.Second, 2 * time.Second, 7 * time.Second, 7 * time.Second, 12 * time.Second}
+	if !reflect.DeepEqual(waits, want) {
+		t.Errorf("waits = %v, want %v", waits, want)
+	}
+}
+
+func TestDeviceFlow_PollDefaultInterval(t *testing.T) {
+	code := DeviceCode{DeviceCode: "dev-code"}
+	server, _ := fakeAuth0(t, code, nil)
+
+	var waits []time.Duration
+	_, err := stubDeviceFlow(server.URL, &waits).PollToken(context.Background(), &code)
+	eq(t, err, nil)
+	eq(t, waits, []time.Duration{5 * time.Second})
+}
+
+func TestDeviceFlow_PollDeadline(t *testing.T) {
+	pending := make([]string, 100)
+	for i := range pending {
+		pending[i] = "authorization_pending"
+	}
+	code := DeviceCode{DeviceCode: "dev-code", ExpiresIn: 30, Interval: 5}
+	server, polls := fakeAuth0(t, code, pending)
+
+	var waits []time.Duration
+	_, err := stubDeviceFlow(server.URL, &waits).PollToken(context.Background(), &code)
+	eq(t, err, ErrDeviceCodeExpired)
+	eq(t, *polls, 5)
+}
+
+func TestDeviceFlow_PollErrors(t *testing.T) {
+	tests := []struct {
+		code    string
+		wantErr error
+	}{
+		{code: "expired_token", wantErr: ErrDeviceCodeExpired},
+		{code: "access_denied", wantErr: ErrAccessDenied},
+		{code: "invalid_grant", wantErr: &OAuthError{StatusCode: http.StatusForbidden, Code: "invalid_grant", Description: "description of invalid_grant"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.code, func(t *testing.T) {
+			code := DeviceCode{DeviceCode: "dev-code", ExpiresIn: 900, Interval: 1}
+			server, _ := fakeAuth0(t, code, []string{tt.code})
+
+			var waits []time.Duration
+			_, err := stubDeviceFlow(server.URL, &waits).PollToken(context.Background(), &code)
+			eq(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestDeviceFlow_PollCancel(t *testing.T) {
+	code := DeviceCode{DeviceCode: "dev-code", ExpiresIn: 900, Interval: 1}
+	server, polls := fakeAuth0(t, code, []string{"authorization_pending"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	flow := &DeviceFlow{BaseURL: server.URL, ClientID: "client-id"}
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		cancel()
+	}()
+
+	_, err := flow.PollToken(ctx, &code)
+	eq(t, err, context.Canceled)
+	eq(t, *polls, 0)
+}
@@ -0,0 +1,142 @@
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+// DefaultKeyCacheTTL is how long cached signing keys are used before they are fetched again
+const DefaultKeyCacheTTL = 24 * time.Hour
+
+// ErrTokenExpired is wrapped by the error ValidateToken returns for an expired token
+var ErrTokenExpired = errors.New("token expired")
+
+// UnknownKeyError is returned when a token is signed with a key that is not in the key set
+type UnknownKeyError struct {
+	KeyID string
+}
+
+func (e *UnknownKeyError) Error() string {
+	if e.KeyID == "" {
+		return "token does not name its signing key and the key set has more than one key"
+	}
+	return fmt.Sprintf("token is signed with unknown key %q", e.KeyID)
+}
+
+// KeyCache looks up Auth0 signing keys by key ID, keeping a copy of the key set
+// on disk. The key set is fetched again when the copy is older than TTL or does
+// not contain the key asked for, so a key rotation at Auth0 is picked up.
+type KeyCache struct {
+	// Fetch downloads the key set
+	Fetch func() ([]byte, error)
+	// Load returns the cached key set and when it was saved
+	Load func() ([]byte, time.Time, error)
+	// Save stores a freshly fetched key set
+	Save func([]byte) error
+	TTL  time.Duration
+	Now  func() time.Time
+}
+
+// Key returns the signing key with the given ID
+func (c *KeyCache) Key(kid string) (*jose.JSONWebKey, error) {
+	now := time.Now()
+	if c.Now != nil {
+		now = c.Now()
+	}
+
+	ttl := c.TTL
+	if ttl == 0 {
+		ttl = DefaultKeyCacheTTL
+	}
+
+	if c.Load != nil {
+		if data, savedAt, err := c.Load(); err == nil && now.Sub(savedAt) < ttl {
+			if keys, err := ParseKeySet(data); err == nil {
+				if key, err := findKey(keys, kid); err == nil {
+					return key, nil
+				}
+			}
+		}
+	}
+
+	data, err := c.Fetch()
+	if err != nil {
+		return nil, fmt.Errorf("could not fetch signing keys: %v", err)
+	}
+	keys, err := ParseKeySet(data)
+	if err != nil {
+		return nil, fmt.Errorf("could not read signing keys: %v", err)
+	}
+	if c.Save != nil {
+		c.Save(data)
+	}
+	return findKey(keys, kid)
+}
+
+// findKey picks a key by ID. A token without a key ID only matches a key set with one key.
+func findKey(keys *jose.JSONWebKeySet, kid string) (*jose.JSONWebKey, error) {
+	if kid == "" {
+		if len(keys.Keys) == 1 {
+			return &keys.Keys[0], nil
+		}
+		return nil, &UnknownKeyError{}
+	}
+	if found := keys.Key(kid); len(found) > 0 {
+		return &found[0], nil
+	}
+	return nil, &UnknownKeyError{KeyID: kid}
+}
+
+// ValidateToken verifies a token's signature with the key named by its kid
+// header, then checks its exp, nbf, iss and aud claims against expected.
+func ValidateToken(token string, keys *KeyCache, expected jwt.Expected) (*Claims, error) {
+	parsed, err := jwt.ParseSigned(token)
+	if err != nil {
+		return nil, fmt.Errorf("token is malformed: %v", err)
+	}
+	if len(parsed.Headers) == 0 {
+		return nil, errors.New("token is malformed: missing header")
+	}
+
+	key, err := keys.Key(parsed.Headers[0].KeyID)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &Claims{}
+	if err = parsed.Claims(key, &claims.Claims, &claims.Raw); err != nil {
+		return nil, fmt.Errorf("token signature is invalid: %v", err)
+	}
+
+	if expected.Time.IsZero() {
+		expected.Time = time.Now()
+		if keys.Now != nil {
+			expected.Time = keys.Now()
+		}
+	}
+	if err = claims.ValidateWithLeeway(expected, jwt.DefaultLeeway); err != nil {
+		return nil, claimError(err, claims, expected)
+	}
+	return claims, nil
+}
+
+// claimError explains why a token's claims did not match what was expected
+func claimError(err error, claims *Claims, expected jwt.Expected) error {
+	switch err {
+	case jwt.ErrExpired:
+		return fmt.Errorf("%w at %s", ErrTokenExpired, claims.Expiry.Time().Format(time.RFC1123))
+	case jwt.ErrNotValidYet, jwt.ErrIssuedInTheFuture:
+		return errors.New("token is not valid yet; check your system clock")
+	case jwt.ErrInvalidIssuer:
+		return fmt.Errorf("token was issued by %q, expected %q; are you logged in to the right environment?", claims.Issuer, expected.Issuer)
+	case jwt.ErrInvalidAudience:
+		return fmt.Errorf("token audience %q does not include %q; are you logged in to the right environment?",
+			strings.Join(claims.Audience, ", "), strings.Join(expected.Audience, ", "))
+	}
+	return err
+}
@@ -0,0 +1,151 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+func TestKeyCache_UsesFreshCache(t *testing.T) {
+	_, keys := newTestKey(t, "key-1")
+	fetches := 0
+	cache := stubKeyCache(t, keys, time.Unix(1000, 0), &fetches, nil)
+	cache.Now = func() time.Time { return time.Unix(1000, 0).Add(time.Hour) }
+
+	key, err := cache.Key("key-1")
+	eq(t, err, nil)
+	eq(t, key.KeyID, "key-1")
+	eq(t, fetches, 0)
+}
+
+func TestKeyCache_RefetchesStaleCache(t *testing.T) {
+	_, keys := newTestKey(t, "key-1")
+	fetches := 0
+	var saved []byte
+	cache := stubKeyCache(t, keys, time.Unix(1000, 0), &fetches, keys)
+	cache.Now = func() time.Time { return time.Unix(1000, 0).Add(25 * time.Hour) }
+	cache.Save = func(data []byte) error {
+		saved = data
+		return nil
+	}
+
+	_, err := cache.Key("key-1")
+	eq(t, err, nil)
+	eq(t, fetches, 1)
+	eq(t, saved != nil, true)
+}
+
+func TestKeyCache_RefetchesOnUnknownKid(t *testing.T) {
+	_, oldKeys := newTestKey(t, "old")
+	_, newKeys := newTestKey(t, "new")
+	fetches := 0
+	cache := stubKeyCache(t, oldKeys, time.Unix(1000, 0), &fetches, newKeys)
+	cache.Now = func() time.Time { return time.Unix(1000, 0) }
+
+	key, err := cache.Key("new")
+	eq(t, err, nil)
+	eq(t, key.KeyID, "new")
+	eq(t, fetches, 1)
+
+	_, err = cache.Key("missing")
+	var unknown *UnknownKeyError
+	eq(t, errors.As(err, &unknown), true)
+	eq(t, unknown.KeyID, "missing")
+}
+
+func TestValidateToken(t *testing.T) {
+	key, keys := newTestKey(t, "key-1")
+	now := time.Unix(10000, 0)
+	expected := jwt.Expected{
+		Issuer:   "https://login.example.com/",
+		Audience: jwt.Audience{"http://api.example.com/api/v1/"},
+	}
+	validClaims := func() map[string]interface{} {
+		return map[string]interface{}{
+			"sub": "auth0|123",
+			"iss": "https://login.example.com/",
+			"aud": []string{"http://api.example.com/api/v1/", "https://login.example.com/userinfo"},
+			"exp": now.Add(time.Hour).Unix(),
+		}
+	}
+
+	tests := []struct {
+		name    string
+		kid     string
+		change  func(map[string]interface{})
+		wantErr string
+	}{
+		{name: "valid", kid: "key-1"},
+		{name: "unknown kid", kid: "key-2", wantErr: `unknown key "key-2"`},
+		{name: "expired", kid: "key-1", change: func(c map[string]interface{}) { c["exp"] = now.Add(-time.Hour).Unix() }, wantErr: "token expired"},
+		{name: "wrong issuer", kid: "key-1", change: func(c map[string]interface{}) { c["iss"] = "https://evil.example.com/" }, wantErr: "issued by"},
+		{name: "wrong audience", kid: "key-1", change: func(c map[string]interface{}) { c["aud"] = "http://api.dev.example.com/api/v1/" }, wantErr: "audience"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := validClaims()
+			if tt.change != nil {
+				tt.change(claims)
+			}
+			token := signTestToken(t, key, tt.kid, claims)
+
+			fetches := 0
+			cache := stubKeyCache(t, keys, now, &fetches, keys)
+			cache.Now = func() time.Time { return now }
+
+			got, err := ValidateToken(token, cache, expected)
+			if tt.name == "expired" {
+				eq(t, errors.Is(err, ErrTokenExpired), true)
+			}
+			if tt.wantErr == "" {
+				eq(t, err, nil)
+				eq(t, got.Subject, "auth0|123")
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidateToken_WrongSigningKey(t *testing.T) {
+	key, _ := newTestKey(t, "key-1")
+	_, otherKeys := newTestKey(t, "key-1")
+	token := signTestToken(t, key, "key-1", map[string]interface{}{"sub": "auth0|123"})
+
+	fetches := 0
+	cache := stubKeyCache(t, otherKeys, time.Now(), &fetches, otherKeys)
+	_, err := ValidateToken(token, cache, jwt.Expected{})
+	if err == nil || !strings.Contains(err.Error(), "signature is invalid") {
+		t.Errorf("expected signature error, got %v", err)
+	}
+}
+
+// Helper functions
+func stubKeyCache(t *testing.T, cached *jose.JSONWebKeySet, savedAt time.Time, fetches *int, remote *jose.JSONWebKeySet) *KeyCache {
+	t.Helper()
+	cachedData, err := json.Marshal(cached)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &KeyCache{
+		Load: func() ([]byte, time.Time, error) {
+			return cachedData, savedAt, nil
+		},
+		Fetch: func() ([]byte, error) {
+			*fetches++
+			if remote == nil {
+				return nil, errors.New("unexpected fetch")
+			}
+			return json.Marshal(remote)
+		},
+		Save: func([]byte) error { return nil },
+	}
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+)
+
+// DefaultLeeway is how long before expiry an access token is refreshed
+const DefaultLeeway = time.Minute
+
+// ErrNoRefreshToken is returned when the access token has expired and cannot be refreshed
+var ErrNoRefreshToken = errors.New("access token expired and no refresh token is stored")
+
+// Manager hands out a valid access token, using the refresh token to get a new
+// one shortly before the current one expires and saving the result. It is
+// safe for concurrent use.
+type Manager struct {
+	Tokens *Auth
+	// Refresh exchanges a refresh token for new tokens
+	Refresh func(refreshToken string) (*Auth, error)
+	// Save persists refreshed tokens
+	Save   func(Auth) error
+	Leeway time.Duration
+	Now    func() time.Time
+
+	mu sync.Mutex
+}
+
+// AccessToken returns the current access token, refreshing it first if it has expired
+func (m *Manager) AccessToken() (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	if m.Now != nil {
+		now = m.Now()
+	}
+
+	leeway := m.Leeway
+	if leeway == 0 {
+		leeway = DefaultLeeway
+	}
+
+	if !m.Tokens.Expired(now, leeway) {
+		return m.Tokens.AccessToken, nil
+	}
+	if m.Tokens.RefreshToken == "" || m.Refresh == nil {
+		return "", ErrNoRefreshToken
+	}
+
+	fresh, err := m.Refresh(m.Tokens.RefreshToken)
+	if err != nil {
+		return "", fmt.Errorf("refreshing access token: %w", err)
+	}
+
+	// Auth0 only returns a new refresh token when rotation is enabled
+	if fresh.RefreshToken == "" {
+		fresh.RefreshToken = m.Tokens.RefreshToken
+	}
+	if fresh.IDToken == "" {
+		fresh.IDToken = m.Tokens.IDToken
+	}
+	fresh.IssuedAt = now.Unix()
+
+	if m.Save != nil {
+		if err = m.Save(*fresh); err != nil {
+			return "", fmt.Errorf("saving refreshed tokens: %w", err)
+		}
+	}
+	m.Tokens = fresh
+	return fresh.AccessToken, nil
+}
+
+// RevokeToken invalidates a refresh token at the Auth0 tenant at baseURL (RFC
+// 7009), so it can no longer be exchanged for access tokens. A nil client uses
+// http.DefaultClient.
+func RevokeToken(ctx context.Context, baseURL, clientID string, client *http.Client, refreshToken string) error {
+	data := url.Values{}
+	data.Set("token", refreshToken)
+	_, err := postForm(ctx, client, baseURL, clientID, "/oauth/revoke", data)
+	return err
+}
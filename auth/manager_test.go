@@ -0,0 +1,140 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestManager_AccessTokenValid(t *testing.T) {
+	now := time.Unix(1000, 0)
+	m := &Manager{
+		Tokens: &Auth{AccessToken: "current", RefreshToken: "refresh", ExpiresIn: 3600, IssuedAt: 1000},
+		Refresh: func(string) (*Auth, error) {
+			t.Fatal("unexpected refresh")
+			return nil, nil
+		},
+		Now: func() time.Time { return now },
+	}
+
+	token, err := m.AccessToken()
+	eq(t, err, nil)
+	eq(t, token, "current")
+}
+
+func TestManager_AccessTokenRefresh(t *testing.T) {
+	now := time.Unix(5000, 0)
+	var saved []Auth
+	var refreshedWith string
+	m := &Manager{
+		Tokens: &Auth{AccessToken: "old", RefreshToken: "refresh", IDToken: "id", ExpiresIn: 3600, IssuedAt: 1000},
+		Refresh: func(refreshToken string) (*Auth, error) {
+			refreshedWith = refreshToken
+			return &Auth{AccessToken: "new", TokenType: "Bearer", ExpiresIn: 3600}, nil
+		},
+		Save: func(a Auth) error {
+			saved = append(saved, a)
+			return nil
+		},
+		Now: func() time.Time { return now },
+	}
+
+	token, err := m.AccessToken()
+	eq(t, err, nil)
+	eq(t, token, "new")
+	eq(t, refreshedWith, "refresh")
+	eq(t, saved, []Auth{{
+		AccessToken:  "new",
+		RefreshToken: "refresh",
+		IDToken:      "id",
+		TokenType:    "Bearer",
+		ExpiresIn:    3600,
+		IssuedAt:     5000,
+	}})
+
+	// refreshed token is reused until it expires
+	token, err = m.AccessToken()
+	eq(t, err, nil)
+	eq(t, token, "new")
+	eq(t, len(saved), 1)
+}
+
+func TestManager_AccessTokenRotatedRefreshToken(t *testing.T) {
+	m := &Manager{
+		Tokens: &Auth{AccessToken: "old", RefreshToken: "refresh-1", ExpiresIn: 60, IssuedAt: 1000},
+		Refresh: func(string) (*Auth, error) {
+			return &Auth{AccessToken: "new", RefreshToken: "refresh-2", ExpiresIn: 60}, nil
+		},
+		Now: func() time.Time { return time.Unix(1030, 0) },
+	}
+
+	_, err := m.AccessToken()
+	eq(t, err, nil)
+	eq(t, m.Tokens.RefreshToken, "refresh-2")
+}
+
+func TestManager_AccessTokenNoRefreshToken(t *testing.T) {
+	m := &Manager{
+		Tokens: &Auth{AccessToken: "old", ExpiresIn: 60, IssuedAt: 1000},
+		Now:    func() time.Time { return time.Unix(2000, 0) },
+	}
+
+	_, err := m.AccessToken()
+	eq(t, err, ErrNoRefreshToken)
+}
+
+func TestManager_AccessTokenRefreshFails(t *testing.T) {
+	m := &Manager{
+		Tokens: &Auth{AccessToken: "old", RefreshToken: "refresh", ExpiresIn: 60, IssuedAt: 1000},
+		Refresh: func(string) (*Auth, error) {
+			return nil, errors.New("invalid_grant")
+		},
+		Now: func() time.Time { return time.Unix(2000, 0) },
+	}
+
+	_, err := m.AccessToken()
+	eq(t, err.Error(), "refreshing access token: invalid_grant")
+}
+
+func TestAuth_ExpiresAtUnknown(t *testing.T) {
+	a := Auth{AccessToken: "not-a-jwt", ExpiresIn: 3600}
+	eq(t, a.ExpiresAt().IsZero(), true)
+	eq(t, a.Expired(time.Now(), 0), false)
+}
+
+func TestRevokeToken(t *testing.T) {
+	var revoked []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Fatal(err)
+		}
+		eq(t, r.URL.Path, "/oauth/revoke")
+		eq(t, r.PostForm.Get("client_id"), "client-id")
+		token := r.PostForm.Get("token")
+		if token != "refresh" {
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_request", "error_description": "token is invalid"})
+			return
+		}
+		revoked = append(revoked, token)
+	}))
+	defer server.Close()
+
+	eq(t, RevokeToken(context.Background(), server.URL, "client-id", nil, "refresh"), nil)
+	eq(t, revoked, []string{"refresh"})
+
+	err := RevokeToken(context.Background(), server.URL, "client-id", nil, "unknown")
+	eq(t, err, &OAuthError{StatusCode: http.StatusBadRequest, Code: "invalid_request", Description: "token is invalid"})
+}
+
+func eq(t *testing.T, got interface{}, expected interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected: %v, got: %v", expected, got)
+	}
+}
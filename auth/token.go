@@ -0,0 +1,72 @@
+package auth
+
+import (
+	"encoding/json"
+	"strings"
+
+	jose "gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+// Claims holds the JWT claims the CLI reads from Auth0 tokens
+type Claims struct {
+	jwt.Claims
+	// Raw holds every claim, including namespaced custom claims
+	Raw map[string]interface{}
+}
+
+// Value returns a string claim by name, also matching Auth0 namespaced custom
+// claims such as "https://featurepeek.com/org" for "org"
+func (c *Claims) Value(name string) string {
+	if c == nil {
+		return ""
+	}
+	if v, ok := c.Raw[name].(string); ok {
+		return v
+	}
+	for key, value := range c.Raw {
+		if strings.HasSuffix(key, "/"+name) {
+			if v, ok := value.(string); ok {
+				return v
+			}
+		}
+	}
+	return ""
+}
+
+// ParseClaims decodes a token's claims without verifying its signature
+func ParseClaims(token string) (*Claims, error) {
+	parsed, err := jwt.ParseSigned(token)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &Claims{}
+	if err = parsed.UnsafeClaimsWithoutVerification(&claims.Claims, &claims.Raw); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// VerifyClaims checks a token's signature against a key set and returns its claims
+func VerifyClaims(token string, keys *jose.JSONWebKeySet) (*Claims, error) {
+	parsed, err := jwt.ParseSigned(token)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := &Claims{}
+	if err = parsed.Claims(keys, &claims.Claims, &claims.Raw); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+// ParseKeySet decodes a JSON Web Key Set
+func ParseKeySet(data []byte) (*jose.JSONWebKeySet, error) {
+	var keys jose.JSONWebKeySet
+	if err := json.Unmarshal(data, &keys); err != nil {
+		return nil, err
+	}
+	return &keys, nil
+}
@@ -0,0 +1,83 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	jose "gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
+)
+
+func TestParseClaims(t *testing.T) {
+	key, keys := newTestKey(t, "key-1")
+	token := signTestToken(t, key, "key-1", map[string]interface{}{
+		"sub":                         "auth0|123",
+		"email":                       "jane@example.com",
+		"https://featurepeek.com/org": "acme",
+		"exp":                         time.Unix(2000, 0).Unix(),
+	})
+
+	claims, err := ParseClaims(token)
+	eq(t, err, nil)
+	eq(t, claims.Subject, "auth0|123")
+	eq(t, claims.Value("email"), "jane@example.com")
+	eq(t, claims.Value("org"), "acme")
+	eq(t, claims.Expiry.Time(), time.Unix(2000, 0))
+
+	verified, err := VerifyClaims(token, keys)
+	eq(t, err, nil)
+	eq(t, verified.Subject, "auth0|123")
+}
+
+func TestVerifyClaims_WrongKey(t *testing.T) {
+	key, _ := newTestKey(t, "key-1")
+	_, otherKeys := newTestKey(t, "key-1")
+	token := signTestToken(t, key, "key-1", map[string]interface{}{"sub": "auth0|123"})
+
+	if _, err := VerifyClaims(token, otherKeys); err == nil {
+		t.Error("expected verification with the wrong key to fail")
+	}
+}
+
+func TestAuth_ExpiresAtFromToken(t *testing.T) {
+	key, _ := newTestKey(t, "key-1")
+	token := signTestToken(t, key, "key-1", map[string]interface{}{"exp": 2000})
+
+	a := Auth{AccessToken: token}
+	eq(t, a.ExpiresAt(), time.Unix(2000, 0))
+	eq(t, a.Expired(time.Unix(1000, 0), time.Minute), false)
+	eq(t, a.Expired(time.Unix(1950, 0), time.Minute), true)
+}
+
+// Helper functions
+func newTestKey(t *testing.T, kid string) (*rsa.PrivateKey, *jose.JSONWebKeySet) {
+	t.Helper()
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key, &jose.JSONWebKeySet{Keys: []jose.JSONWebKey{{
+		Key:       &key.PublicKey,
+		KeyID:     kid,
+		Algorithm: string(jose.RS256),
+		Use:       "sig",
+	}}}
+}
+
+func signTestToken(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	t.Helper()
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: key},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", kid),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := jwt.Signed(signer).Claims(claims).CompactSerialize()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
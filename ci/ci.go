@@ -0,0 +1,210 @@
+// Package ci detects the CI provider peek runs under and reads the details of
+// the build from the environment variables the provider sets
+package ci
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"peek/git"
+	"strconv"
+	"strings"
+)
+
+// Build is the commit a CI job runs for. Fields the provider does not set are empty.
+type Build struct {
+	// Provider is the name of the CI service, such as "GitHub Actions"
+	Provider string
+	Owner    string
+	Repo     string
+	Branch   string
+	SHA      string
+	// PullRequest is the number of the pull or merge request the build runs for, if any
+	PullRequest int
+	// BuildURL links to the build in the provider's UI
+	BuildURL string
+}
+
+// provider reads a Build from the environment of one CI service
+type provider struct {
+	name   string
+	detect func(getenv func(string) string) bool
+	build  func(getenv func(string) string) Build
+}
+
+var providers = []provider{
+	{"GitHub Actions", isTrue("GITHUB_ACTIONS"), githubActions},
+	{"GitLab CI", isTrue("GITLAB_CI"), gitlabCI},
+	{"CircleCI", isTrue("CIRCLECI"), circleCI},
+	{"Buildkite", isTrue("BUILDKITE"), buildkite},
+	{"Jenkins", isSet("JENKINS_URL"), jenkins},
+}
+
+// Detect returns the build details of the CI provider whose environment
+// variables are set, or nil when not running under a supported provider.
+// getenv is normally os.Getenv.
+func Detect(getenv func(string) string) *Build {
+	for _, p := range providers {
+		if p.detect(getenv) {
+			build := p.build(getenv)
+			build.Provider = p.name
+			return &build
+		}
+	}
+	return nil
+}
+
+func isTrue(name string) func(func(string) string) bool {
+	return func(getenv func(string) string) bool {
+		return strings.EqualFold(getenv(name), "true")
+	}
+}
+
+func isSet(name string) func(func(string) string) bool {
+	return func(getenv func(string) string) bool {
+		return getenv(name) != ""
+	}
+}
+
+// githubActions reads the variables described at
+// https://docs.github.com/actions/learn-github-actions/variables. For pull
+// requests GITHUB_SHA is a merge commit that only exists on GitHub, so the
+// head commit of the pull request is read from the event payload instead.
+func githubActions(getenv func(string) string) Build {
+	build := Build{
+		Branch: getenv("GITHUB_HEAD_REF"),
+		SHA:    getenv("GITHUB_SHA"),
+	}
+	build.Owner, build.Repo = splitRepoPath(getenv("GITHUB_REPOSITORY"))
+
+	ref := getenv("GITHUB_REF")
+	if build.Branch == "" && strings.HasPrefix(ref, "refs/heads/") {
+		build.Branch = strings.TrimPrefix(ref, "refs/heads/")
+	}
+	if strings.HasPrefix(ref, "refs/pull/") {
+		build.PullRequest = parseNumber(strings.Split(ref, "/")[2])
+	}
+
+	if build.PullRequest != 0 {
+		var event struct {
+			PullRequest struct {
+				Head struct {
+					SHA string `json:"sha"`
+				} `json:"head"`
+			} `json:"pull_request"`
+		}
+		if data, err := ioutil.ReadFile(getenv("GITHUB_EVENT_PATH")); err == nil && json.Unmarshal(data, &event) == nil {
+			if sha := event.PullRequest.Head.SHA; sha != "" {
+				build.SHA = sha
+			}
+		}
+	}
+
+	if server, runID := getenv("GITHUB_SERVER_URL"), getenv("GITHUB_RUN_ID"); server != "" && runID != "" {
+		build.BuildURL = server + "/" + getenv("GITHUB_REPOSITORY") + "/actions/runs/" + runID
+	}
+	return build
+}
+
+// gitlabCI reads the variables described at https://docs.gitlab.com/ee/ci/variables/predefined_variables.html
+func gitlabCI(getenv func(string) string) Build {
+	return Build{
+		Owner:       getenv("CI_PROJECT_NAMESPACE"),
+		Repo:        getenv("CI_PROJECT_NAME"),
+		Branch:      firstSet(getenv("CI_MERGE_REQUEST_SOURCE_BRANCH_NAME"), getenv("CI_COMMIT_BRANCH")),
+		SHA:         firstSet(getenv("CI_MERGE_REQUEST_SOURCE_BRANCH_SHA"), getenv("CI_COMMIT_SHA")),
+		PullRequest: parseNumber(getenv("CI_MERGE_REQUEST_IID")),
+		BuildURL:    getenv("CI_JOB_URL"),
+	}
+}
+
+// circleCI reads the variables described at https://circleci.com/docs/variables/
+func circleCI(getenv func(string) string) Build {
+	build := Build{
+		Owner:       getenv("CIRCLE_PROJECT_USERNAME"),
+		Repo:        getenv("CIRCLE_PROJECT_REPONAME"),
+		Branch:      getenv("CIRCLE_BRANCH"),
+		SHA:         getenv("CIRCLE_SHA1"),
+		PullRequest: parseNumber(getenv("CIRCLE_PR_NUMBER")),
+		BuildURL:    getenv("CIRCLE_BUILD_URL"),
+	}
+	if build.PullRequest == 0 {
+		// CIRCLE_PULL_REQUEST is the URL of the pull request, ending in its number
+		pr := getenv("CIRCLE_PULL_REQUEST")
+		build.PullRequest = parseNumber(pr[strings.LastIndex(pr, "/")+1:])
+	}
+	return build
+}
+
+// buildkite reads the variables described at https://buildkite.com/docs/pipelines/environment-variables
+func buildkite(getenv func(string) string) Build {
+	build := Build{
+		Branch:      getenv("BUILDKITE_BRANCH"),
+		SHA:         getenv("BUILDKITE_COMMIT"),
+		PullRequest: parseNumber(getenv("BUILDKITE_PULL_REQUEST")),
+		BuildURL:    getenv("BUILDKITE_BUILD_URL"),
+	}
+	// builds triggered without a commit report HEAD until the checkout resolves it
+	if build.SHA == "HEAD" {
+		build.SHA = ""
+	}
+	build.Owner, build.Repo = repoFromURL(getenv("BUILDKITE_REPO"))
+	return build
+}
+
+// jenkins reads the variables set by Jenkins and its Git and multibranch pipeline plugins
+func jenkins(getenv func(string) string) Build {
+	build := Build{
+		Branch:      firstSet(getenv("CHANGE_BRANCH"), getenv("BRANCH_NAME"), getenv("GIT_LOCAL_BRANCH")),
+		SHA:         getenv("GIT_COMMIT"),
+		PullRequest: parseNumber(getenv("CHANGE_ID")),
+		BuildURL:    getenv("BUILD_URL"),
+	}
+	if build.Branch == "" {
+		// the Git plugin reports the remote tracking branch, such as origin/main
+		if branch := getenv("GIT_BRANCH"); strings.Contains(branch, "/") {
+			build.Branch = branch[strings.Index(branch, "/")+1:]
+		}
+	}
+	build.Owner, build.Repo = repoFromURL(getenv("GIT_URL"))
+	return build
+}
+
+// repoFromURL returns the owner and name of the repository at a git remote URL
+func repoFromURL(rawURL string) (string, string) {
+	if rawURL == "" {
+		return "", ""
+	}
+	u, err := git.ParseURL(rawURL)
+	if err != nil {
+		return "", ""
+	}
+	return splitRepoPath(strings.TrimSuffix(strings.Trim(u.Path, "/"), ".git"))
+}
+
+// splitRepoPath splits an "owner/repo" path. The owner may contain slashes, as
+// GitLab subgroups do.
+func splitRepoPath(path string) (string, string) {
+	i := strings.LastIndex(path, "/")
+	if i < 0 {
+		return "", ""
+	}
+	return path[:i], path[i+1:]
+}
+
+// parseNumber parses a pull request number, returning 0 for anything else, such as "false"
+func parseNumber(value string) int {
+	n, err := strconv.Atoi(value)
+	if err != nil || n < 0 {
+		return 0
+	}
+	return n
+}
+
+func firstSet(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
@@ -0,0 +1,180 @@
+package ci
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestDetect(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		want *Build
+	}{
+		{
+			name: "not in CI",
+			env:  map[string]string{"CI": "true"},
+			want: nil,
+		},
+		{
+			name: "GitHub Actions push",
+			env: map[string]string{
+				"GITHUB_ACTIONS":    "true",
+				"GITHUB_REPOSITORY": "acme/site",
+				"GITHUB_REF":        "refs/heads/feature/login",
+				"GITHUB_SHA":        "1111111",
+				"GITHUB_SERVER_URL": "https://github.com",
+				"GITHUB_RUN_ID":     "99",
+			},
+			want: &Build{
+				Provider: "GitHub Actions",
+				Owner:    "acme",
+				Repo:     "site",
+				Branch:   "feature/login",
+				SHA:      "1111111",
+				BuildURL: "https://github.com/acme/site/actions/runs/99",
+			},
+		},
+		{
+			name: "GitHub Actions tag",
+			env: map[string]string{
+				"GITHUB_ACTIONS":    "true",
+				"GITHUB_REPOSITORY": "acme/site",
+				"GITHUB_REF":        "refs/tags/v1.0.0",
+				"GITHUB_SHA":        "1111111",
+			},
+			want: &Build{Provider: "GitHub Actions", Owner: "acme", Repo: "site", SHA: "1111111"},
+		},
+		{
+			name: "GitLab merge request",
+			env: map[string]string{
+				"GITLAB_CI":                           "true",
+				"CI_PROJECT_NAMESPACE":                "acme/web",
+				"CI_PROJECT_NAME":                     "site",
+				"CI_COMMIT_SHA":                       "2222222",
+				"CI_MERGE_REQUEST_SOURCE_BRANCH_NAME": "feature",
+				"CI_MERGE_REQUEST_IID":                "7",
+				"CI_JOB_URL":                          "https://gitlab.com/acme/web/site/-/jobs/5",
+			},
+			want: &Build{
+				Provider:    "GitLab CI",
+				Owner:       "acme/web",
+				Repo:        "site",
+				Branch:      "feature",
+				SHA:         "2222222",
+				PullRequest: 7,
+				BuildURL:    "https://gitlab.com/acme/web/site/-/jobs/5",
+			},
+		},
+		{
+			name: "CircleCI pull request",
+			env: map[string]string{
+				"CIRCLECI":                "true",
+				"CIRCLE_PROJECT_USERNAME": "acme",
+				"CIRCLE_PROJECT_REPONAME": "site",
+				"CIRCLE_BRANCH":           "feature",
+				"CIRCLE_SHA1":             "3333333",
+				"CIRCLE_PULL_REQUEST":     "https://github.com/acme/site/pull/12",
+				"CIRCLE_BUILD_URL":        "https://circleci.com/gh/acme/site/3",
+			},
+			want: &Build{
+				Provider:    "CircleCI",
+				Owner:       "acme",
+				Repo:        "site",
+				Branch:      "feature",
+				SHA:         "3333333",
+				PullRequest: 12,
+				BuildURL:    "https://circleci.com/gh/acme/site/3",
+			},
+		},
+		{
+			name: "Buildkite",
+			env: map[string]string{
+				"BUILDKITE":              "true",
+				"BUILDKITE_REPO":         "git@github.com:acme/site.git",
+				"BUILDKITE_BRANCH":       "main",
+				"BUILDKITE_COMMIT":       "HEAD",
+				"BUILDKITE_PULL_REQUEST": "false",
+				"BUILDKITE_BUILD_URL":    "https://buildkite.com/acme/site/builds/4",
+			},
+			want: &Build{
+				Provider: "Buildkite",
+				Owner:    "acme",
+				Repo:     "site",
+				Branch:   "main",
+				BuildURL: "https://buildkite.com/acme/site/builds/4",
+			},
+		},
+		{
+			name: "Jenkins",
+			env: map[string]string{
+				"JENKINS_URL": "https://ci.acme.test/",
+				"GIT_URL":     "https://github.com/acme/site.git",
+				"GIT_BRANCH":  "origin/release/1.x",
+				"GIT_COMMIT":  "5555555",
+				"BUILD_URL":   "https://ci.acme.test/job/site/8/",
+			},
+			want: &Build{
+				Provider: "Jenkins",
+				Owner:    "acme",
+				Repo:     "site",
+				Branch:   "release/1.x",
+				SHA:      "5555555",
+				BuildURL: "https://ci.acme.test/job/site/8/",
+			},
+		},
+		{
+			name: "Jenkins multibranch pull request",
+			env: map[string]string{
+				"JENKINS_URL":   "https://ci.acme.test/",
+				"BRANCH_NAME":   "PR-3",
+				"CHANGE_BRANCH": "feature",
+				"CHANGE_ID":     "3",
+				"GIT_COMMIT":    "6666666",
+			},
+			want: &Build{Provider: "Jenkins", Branch: "feature", SHA: "6666666", PullRequest: 3},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			eq(t, Detect(stubGetenv(tt.env)), tt.want)
+		})
+	}
+}
+
+func TestDetect_GitHubPullRequest(t *testing.T) {
+	eventPath := filepath.Join(t.TempDir(), "event.json")
+	event := `{"pull_request":{"number":42,"head":{"sha":"abcdef0","ref":"feature"}}}`
+	if err := ioutil.WriteFile(eventPath, []byte(event), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	build := Detect(stubGetenv(map[string]string{
+		"GITHUB_ACTIONS":    "true",
+		"GITHUB_REPOSITORY": "acme/site",
+		"GITHUB_REF":        "refs/pull/42/merge",
+		"GITHUB_HEAD_REF":   "feature",
+		"GITHUB_SHA":        "merge00",
+		"GITHUB_EVENT_PATH": eventPath,
+	}))
+	eq(t, build.Branch, "feature")
+	eq(t, build.SHA, "abcdef0")
+	eq(t, build.PullRequest, 42)
+}
+
+// Helper functions
+func stubGetenv(env map[string]string) func(string) string {
+	return func(name string) string {
+		return env[name]
+	}
+}
+
+func eq(t *testing.T, got interface{}, expected interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected: %v, got: %v", expected, got)
+	}
+}
@@ -0,0 +1,58 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"peek/peekconfig"
+	"peek/run"
+	"runtime"
+	"sort"
+)
+
+// buildCommand prepares a service's build command to run through the shell from
+// rootDir, with the service's env added to the current environment
+func buildCommand(service *peekconfig.SimpleService, rootDir string) *exec.Cmd {
+	var buildCmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		buildCmd = exec.Command("cmd", "/C", service.Build)
+	} else {
+		buildCmd = exec.Command("sh", "-c", service.Build)
+	}
+	buildCmd.Dir = rootDir
+
+	keys := make([]string, 0, len(service.Env))
+	for k := range service.Env {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	buildCmd.Env = os.Environ()
+	for _, k := range keys {
+		buildCmd.Env = append(buildCmd.Env, fmt.Sprintf("%s=%s", k, service.Env[k]))
+	}
+
+	buildCmd.Stdout = os.Stdout
+	buildCmd.Stderr = os.Stderr
+	return buildCmd
+}
+
+// buildServices runs the build command of each service that has one, in order,
+// streaming its output to out. It stops at the first build that fails.
+func buildServices(services []*peekconfig.SimpleService, rootDir string, out io.Writer) error {
+	for _, service := range services {
+		if service.Build == "" {
+			continue
+		}
+
+		fmt.Fprintf(out, "Building %s: %s\n\n", service.Name, service.Build)
+		buildCmd := buildCommand(service, rootDir)
+		buildCmd.Stdout = out
+		if err := run.PrepareCmd(buildCmd).Run(); err != nil {
+			return fmt.Errorf("build for %s failed: %v", service.Name, err)
+		}
+		fmt.Fprintln(out)
+	}
+	return nil
+}
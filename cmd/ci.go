@@ -0,0 +1,122 @@
+package cmd
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"peek/api"
+	"peek/ci"
+	"peek/config"
+	"peek/git"
+	"strings"
+)
+
+var ciFlag bool
+
+// ciTokens returns the access token in PEEK_TOKEN and the endpoints of the
+// selected profile, which need not exist or have credentials
+func ciTokens() (config.Environment, api.TokenSource) {
+	token := strings.TrimSpace(os.Getenv(config.TokenEnv))
+	if token == "" {
+		log.Fatalf("Error: %s is not set.\nCreate a personal access token or API key and add it to your CI secrets as %s.", config.TokenEnv, config.TokenEnv)
+	}
+
+	// only the profile's environment is used, so its credentials are not needed
+	profile, _ := config.LoadProfile(activeProfile(), devFlag)
+	return environment(profile), api.StaticToken(token)
+}
+
+// ciTarget fills in the commit to deploy from the CI provider's environment,
+// falling back to the local checkout for anything the provider does not set.
+// The checked out commit is taken to be on the remote already, so none of the
+// interactive checks of a local deploy are made.
+func ciTarget(target *deployTarget) {
+	if build := ci.Detect(os.Getenv); build != nil {
+		target.org, target.repo = build.Owner, build.Repo
+		target.branch, target.sha = build.Branch, build.SHA
+		target.pullRequest = build.PullRequest
+		target.buildURL = build.BuildURL
+	}
+
+	if refRevision() != "" {
+		resolveRefTarget(target)
+	}
+
+	var err error
+	if target.sha == "" {
+		if target.sha, err = git.CurrentSha(); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+	}
+	if target.branch == "" {
+		if target.branch, err = git.CurrentBranch(); err != nil {
+			// a tag or commit given with --ref or --sha is deployed against a branch that contains it
+			branches, _ := git.RemoteBranchesContaining("origin", target.sha)
+			if refRevision() == "" || len(branches) == 0 {
+				log.Fatalf("Error: could not determine the branch to deploy: %v", err)
+			}
+			target.branch = containingBranch(branches)
+		}
+	}
+	if target.org == "" || target.repo == "" {
+		target.org, target.repo = originRepo()
+	}
+}
+
+// ciResult is the JSON printed for each service by `peek deploy --ci`
+type ciResult struct {
+	Service string `json:"service"`
+	Status  int    `json:"status,omitempty"`
+	URL     string `json:"url,omitempty"`
+	Message string `json:"message,omitempty"`
+	Error   string `json:"error,omitempty"`
+}
+
+// ciOutput is the JSON printed by `peek deploy --ci`
+type ciOutput struct {
+	Org         string     `json:"org"`
+	Repo        string     `json:"repo"`
+	Branch      string     `json:"branch"`
+	SHA         string     `json:"sha"`
+	Tag         string     `json:"tag,omitempty"`
+	PullRequest int        `json:"pull_request,omitempty"`
+	BuildURL    string     `json:"build_url,omitempty"`
+	Services    []ciResult `json:"services"`
+}
+
+// printCIResults writes the deploy results to stdout as JSON and returns the number that failed
+func printCIResults(target deployTarget, results []deployResult) int {
+	output := ciOutput{
+		Org:         target.org,
+		Repo:        target.repo,
+		Branch:      target.branch,
+		SHA:         target.sha,
+		Tag:         target.tag,
+		PullRequest: target.pullRequest,
+		BuildURL:    target.buildURL,
+		Services:    []ciResult{},
+	}
+
+	failed := 0
+	for _, result := range results {
+		r := ciResult{Service: result.service, Status: result.statusCode}
+		switch {
+		case result.err != nil:
+			failed++
+			r.Error = result.err.Error()
+		case result.statusCode == http.StatusOK:
+			r.Message = strings.TrimSpace(result.body)
+		default:
+			r.URL = strings.TrimSpace(result.body)
+		}
+		output.Services = append(output.Services, r)
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(output); err != nil {
+		log.Fatal(err)
+	}
+	return failed
+}
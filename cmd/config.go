@@ -0,0 +1,111 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"peek/git"
+	"peek/peekconfig"
+
+	"github.com/spf13/cobra"
+)
+
+var validateJSONFlag bool
+
+// configCmd groups the commands that work with peek.yml
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Work with the peek.yml config",
+}
+
+// configValidation is the machine-readable result of `peek config validate`
+type configValidation struct {
+	Valid    bool                        `json:"valid"`
+	File     string                      `json:"file"`
+	Version  int                         `json:"version,omitempty"`
+	Error    string                      `json:"error,omitempty"`
+	Errors   peekconfig.ValidationErrors `json:"errors,omitempty"`
+	Services []peekconfig.ServiceReport  `json:"services,omitempty"`
+}
+
+// configValidateCmd represents the config validate command
+var configValidateCmd = &cobra.Command{
+	Use:   "validate",
+	Short: "Check peek.yml for errors",
+	Long: `Check peek.yml for errors without deploying.
+
+The peek.yml at the root of the repository is checked against the config
+schema, and every static service's path must exist in the repository and
+contain an index.html. No login or network access is needed, so this can run
+as a pre-commit hook. The command exits non-zero if any check fails.`,
+	Run: func(cmd *cobra.Command, args []string) {
+		rootDir, err := git.ToplevelDir()
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		result := configValidation{File: filepath.Join(rootDir, "peek.yml")}
+		project, err := peekconfig.LoadProject(result.File)
+		switch err := err.(type) {
+		case nil:
+			result.Version = project.Version
+			result.Services = peekconfig.CheckServices(project, rootDir)
+			result.Valid = true
+			for _, service := range result.Services {
+				result.Valid = result.Valid && service.OK()
+			}
+		case peekconfig.ValidationErrors:
+			result.Errors = err
+		default:
+			if os.IsNotExist(err) {
+				result.Error = "no peek.yml config found; run `peek init` to create one"
+			} else {
+				result.Error = err.Error()
+			}
+		}
+
+		if validateJSONFlag {
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(result); err != nil {
+				log.Fatal(err)
+			}
+		} else {
+			printConfigValidation(result)
+		}
+
+		if !result.Valid {
+			os.Exit(1)
+		}
+	},
+}
+
+func printConfigValidation(result configValidation) {
+	if result.Error != "" {
+		fmt.Printf("✗ %s\n", result.Error)
+		return
+	}
+	for _, err := range result.Errors {
+		fmt.Printf("✗ %v\n", err)
+	}
+	for _, service := range result.Services {
+		if service.OK() {
+			fmt.Printf("✓ %s (%s)\n", service.Name, service.Type)
+			continue
+		}
+		for _, problem := range service.Problems {
+			fmt.Printf("✗ %s: %s\n", service.Name, problem)
+		}
+	}
+	if result.Valid {
+		fmt.Println("\npeek.yml is valid")
+	}
+}
+
+func init() {
+	configValidateCmd.Flags().BoolVar(&validateJSONFlag, "json", false, "print results as JSON")
+	configCmd.AddCommand(configValidateCmd)
+	rootCmd.AddCommand(configCmd)
+}
@@ -0,0 +1,204 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"peek/api"
+	"peek/manifest"
+	"peek/peekconfig"
+	"peek/upload"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/spf13/cobra"
+)
+
+// deployTarget holds what every service in a single peek run is deployed against
+type deployTarget struct {
+	rootDir string
+	org     string
+	repo    string
+	sha     string
+	branch  string
+	// tag is the tag name given with --ref, if any
+	tag string
+	// pullRequest is the number of the pull request a CI build runs for, if any
+	pullRequest int
+	// buildURL links to the CI build that deployed, if any
+	buildURL string
+	client   *api.Client
+}
+
+// deployResult is the outcome of deploying a single service
+type deployResult struct {
+	service    string
+	statusCode int
+	body       string
+	err        error
+}
+
+// splitServiceNames parses the comma-separated --service flag
+func splitServiceNames(value string) []string {
+	var names []string
+	for _, name := range strings.Split(value, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// firstServiceName returns the first of the names parsed from --service, or ""
+// to pick the default service
+func firstServiceName(names []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+	return names[0]
+}
+
+// deployServices packages and uploads each service concurrently, returning results in the same order
+func deployServices(services []*peekconfig.SimpleService, target deployTarget) []deployResult {
+	results := make([]deployResult, len(services))
+	var wg sync.WaitGroup
+	for i, service := range services {
+		wg.Add(1)
+		go func(i int, service *peekconfig.SimpleService) {
+			defer wg.Done()
+			results[i] = deployService(service, target)
+		}(i, service)
+	}
+	wg.Wait()
+	return results
+}
+
+func deployService(service *peekconfig.SimpleService, target deployTarget) deployResult {
+	result := deployResult{service: service.Name}
+
+	assetPath := filepath.Join(target.rootDir, service.Path)
+	if _, err := os.Stat(assetPath); err != nil {
+		result.err = fmt.Errorf("Error reading directory: %v", err)
+		return result
+	}
+
+	assetManifest, err := manifest.Build(assetPath)
+	if err != nil {
+		result.err = fmt.Errorf("Error reading directory: %v", err)
+		return result
+	}
+
+	form := upload.NewForm(assetPath)
+	form.AddField("app", service.Name)
+	form.AddField("service", "cli")
+	form.AddField("org", target.org)
+	form.AddField("repo", target.repo)
+	form.AddField("sha", target.sha)
+	form.AddField("branch", target.branch)
+	if target.tag != "" {
+		form.AddField("tag", target.tag)
+	}
+	if target.pullRequest != 0 {
+		form.AddField("pull_request", strconv.Itoa(target.pullRequest))
+	}
+	form.AddField("checksum", assetManifest.Checksum())
+	form.AddField("manifest", assetManifest.String())
+
+	var response *api.Response
+	switch {
+	case incrementalFlag:
+		response, err = uploadIncremental(form, assetManifest, target.client)
+	case chunkedFlag:
+		response, err = uploadChunked(form, target.client)
+	default:
+		response, err = uploadStream(form, target.client)
+	}
+	if err != nil {
+		var apiErr *api.APIError
+		if errors.As(err, &apiErr) {
+			err = fmt.Errorf("Upload failed: %v", err)
+		}
+		result.err = err
+		return result
+	}
+
+	result.statusCode = response.StatusCode
+	result.body = string(response.Body)
+	return result
+}
+
+// printDeployResult reports a single service deployment the way peek always has
+func printDeployResult(result deployResult) {
+	if result.statusCode == http.StatusOK {
+		fmt.Println(result.body)
+	} else {
+		fmt.Printf("Assets uploaded successfully! %s\n", randomEmoji())
+		fmt.Printf("Visit your deployment preview here: %s\n", result.body)
+	}
+}
+
+// printDeploySummary reports every service deployment and returns the number that failed
+func printDeploySummary(results []deployResult) int {
+	width := 0
+	for _, result := range results {
+		if len(result.service) > width {
+			width = len(result.service)
+		}
+	}
+
+	failed := 0
+	for _, result := range results {
+		switch {
+		case result.err != nil:
+			failed++
+			fmt.Printf("  %-*s  failed: %v\n", width, result.service, result.err)
+		default:
+			fmt.Printf("  %-*s  %s\n", width, result.service, strings.TrimSpace(result.body))
+		}
+	}
+
+	fmt.Println()
+	if failed == 0 {
+		fmt.Printf("All %d services uploaded successfully! %s\n", len(results), randomEmoji())
+	} else {
+		fmt.Printf("%d of %d services failed to upload.\n", failed, len(results))
+	}
+	return failed
+}
+
+// deployCmd is the root command under a name that reads well in CI scripts
+var deployCmd = &cobra.Command{
+	Use:   "deploy",
+	Short: "Launch a FeaturePeek deployment preview",
+	Long: `Launch a FeaturePeek deployment preview of the current commit.
+
+This is the same as running ` + "`peek`" + ` on its own.
+
+If origin does not have your latest commits or peek.yml, peek offers to push
+the current branch, setting its upstream if it has none. --push pushes without
+asking.
+
+To deploy a release tag or an older commit, check it out and pass --ref <tag>
+or --sha <commit>. The commit must be in the history of a branch on origin,
+and the tag name is sent along with it.
+
+In a CI pipeline use --ci, which never prompts, reads an access token from
+the PEEK_TOKEN environment variable instead of the credentials saved by
+` + "`peek login`" + `, and takes the branch, commit and pull request number from
+the CI provider's environment instead of the local checkout. GitHub Actions,
+GitLab CI, CircleCI, Buildkite and Jenkins are supported. Build output goes
+to stderr and the results are printed to stdout as JSON.`,
+	Example: `  peek deploy
+  peek deploy --ref v1.4.0
+  PEEK_TOKEN=... peek deploy --ci --all`,
+	Args: cobra.NoArgs,
+	Run:  deployCommand,
+}
+
+func init() {
+	addDeployFlags(deployCmd.Flags())
+	rootCmd.AddCommand(deployCmd)
+}
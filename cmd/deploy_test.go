@@ -0,0 +1,13 @@
+package cmd
+
+import "testing"
+
+func TestSplitServiceNames(t *testing.T) {
+	eq(t, splitServiceNames(""), []string(nil))
+	eq(t, splitServiceNames(" web"), []string{"web"})
+	eq(t, splitServiceNames("web,"), []string{"web"})
+	eq(t, splitServiceNames("web, docs"), []string{"web", "docs"})
+
+	eq(t, firstServiceName(splitServiceNames("web,")), "web")
+	eq(t, firstServiceName(nil), "")
+}
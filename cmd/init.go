@@ -2,14 +2,24 @@
 package cmd
 
 import (
+	"bufio"
 	"fmt"
+	"io"
 	"log"
+	"os"
+	"path/filepath"
+	"peek/git"
 	"peek/peekconfig"
 	"strings"
 
 	"github.com/spf13/cobra"
 )
 
+var initPathFlag string
+var initSpaFlag bool
+var initYesFlag bool
+var initForceFlag bool
+
 // initCmd represents the init command
 var initCmd = &cobra.Command{
 	Use:   "init",
@@ -18,34 +28,103 @@ var initCmd = &cobra.Command{
 
 This command will run the user through a wizard to determine what settings to use
 when creating the local peek.yml config file. Once the questions are answered, the new
-config file will be created in the local directory and it should be immedeately commited
-to git and pushed to remote.`,
+config file will be created at the root of the repository and it should be immedeately commited
+to git and pushed to remote.
+
+Common frameworks (Create React App, Vite, Next.js static export, Angular, Hugo and
+Jekyll) are detected to suggest the build directory and SPA setting. Answers can be
+given with --path and --spa, and --yes accepts the suggestions without prompting.
+The service is named main unless --service is given.`,
+	Example: `  peek init
+  peek init --path build --spa --service web --yes`,
 	Run: func(cmd *cobra.Command, args []string) {
-		var pathInput string
-		var spaInput string
+		serviceName := targetService
+		if serviceName == "" {
+			serviceName = "main"
+		}
+		if strings.Contains(serviceName, ",") {
+			log.Fatal("peek init creates a single service; pass one name to --service.")
+		}
+
+		rootDir, err := git.ToplevelDir()
+		if err != nil {
+			log.Fatal(err)
+		}
+		peekConfigFilename := filepath.Join(rootDir, "peek.yml")
+		if fileExists(peekConfigFilename) && !initForceFlag {
+			log.Fatal("peek.yml already exists. Use --force to overwrite it.")
+		}
+
+		framework := peekconfig.DetectFramework(rootDir)
+
+		pathInput := initPathFlag
+		spaInput := initSpaFlag
+		spaSet := cmd.Flags().Changed("spa")
 
-		fmt.Println("Initializing peek.yml config for static app...")
-		fmt.Println("\nEnter path of statically built assets, relative to repo root:")
-		for pathInput == "" {
-			fmt.Print("--> ")
-			fmt.Scanln(&pathInput)
+		if framework != nil {
+			fmt.Printf("Detected %s project.\n", framework.Name)
+			if pathInput == "" && initYesFlag {
+				pathInput = framework.Path
+			}
+			if !spaSet && initYesFlag {
+				spaInput, spaSet = framework.Spa, true
+			}
 		}
 
-		fmt.Println("Is your project a Single Page Application? (y/n)")
-		for spaInput == "" {
-			fmt.Print("--> ")
-			fmt.Scanln(&spaInput)
+		if initYesFlag {
+			if pathInput == "" {
+				log.Fatal("Could not detect your build directory. Pass it with --path.")
+			}
+			spaSet = true
+		}
+
+		if pathInput == "" || !spaSet {
+			fmt.Println("Initializing peek.yml config for static app...")
+		}
+		input := bufio.NewReader(os.Stdin)
+
+		if pathInput == "" {
+			var defaultPath string
+			if framework != nil {
+				defaultPath = framework.Path
+			}
+			fmt.Println("\nEnter path of statically built assets, relative to repo root:")
+			for pathInput == "" {
+				if pathInput, err = prompt(input, defaultPath); err != nil {
+					log.Fatalf("\nError reading input: %v", err)
+				}
+			}
+		}
+
+		if !spaSet {
+			defaultSpa := ""
+			if framework != nil {
+				defaultSpa = "n"
+				if framework.Spa {
+					defaultSpa = "y"
+				}
+			}
+			fmt.Println("Is your project a Single Page Application? (y/n)")
+			var answer string
+			for answer == "" {
+				if answer, err = prompt(input, defaultSpa); err != nil {
+					log.Fatalf("\nError reading input: %v", err)
+				}
+			}
+			spaInput = strings.ToLower(answer)[0] == 'y'
 		}
 
 		peekConfig := peekconfig.Config{
-			Version: 2,
-			Main: peekconfig.Service{
-				Type: "static",
-				Path: pathInput,
-				Spa:  strings.ToLower(spaInput)[0] == 'y',
+			Version: peekconfig.CurrentVersion,
+			Services: map[string]peekconfig.Service{
+				serviceName: {
+					Type: "static",
+					Path: pathInput,
+					Spa:  spaInput,
+				},
 			},
 		}
-		if err := peekConfig.Save(); err != nil {
+		if err := peekConfig.Save(peekConfigFilename); err != nil {
 			log.Fatal(err)
 		}
 		fmt.Println("\npeek.yml saved!")
@@ -53,6 +132,29 @@ to git and pushed to remote.`,
 	},
 }
 
+// prompt reads a line of input, returning defaultValue if the line is empty
+func prompt(input *bufio.Reader, defaultValue string) (string, error) {
+	if defaultValue != "" {
+		fmt.Printf("--> [%s] ", defaultValue)
+	} else {
+		fmt.Print("--> ")
+	}
+
+	line, err := input.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", err
+	}
+
+	if line = strings.TrimSpace(line); line == "" {
+		return defaultValue, nil
+	}
+	return line, nil
+}
+
 func init() {
+	initCmd.Flags().StringVar(&initPathFlag, "path", "", "path of statically built assets, relative to repo root")
+	initCmd.Flags().BoolVar(&initSpaFlag, "spa", false, "project is a Single Page Application")
+	initCmd.Flags().BoolVarP(&initYesFlag, "yes", "y", false, "accept detected settings without prompting")
+	initCmd.Flags().BoolVar(&initForceFlag, "force", false, "overwrite an existing peek.yml")
 	rootCmd.AddCommand(initCmd)
 }
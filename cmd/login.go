@@ -2,14 +2,18 @@
 package cmd
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"log"
 	"net/http"
 	"net/url"
 	"os"
+	"os/signal"
 	"path"
+	"peek/api"
 	"peek/auth"
 	"peek/config"
 	"peek/spinner"
@@ -18,64 +22,31 @@ import (
 
 	"github.com/skratchdot/open-golang/open"
 	"github.com/spf13/cobra"
-	jose "gopkg.in/square/go-jose.v2"
+	"gopkg.in/square/go-jose.v2/jwt"
 )
 
-const devAuth0BaseURL = "https://featurepeek-dev.auth0.com"
-const prodAuth0BaseURL = "https://login.featurepeek.com"
-const devClientID = "XnNVx0nzQSJdY6ksPGTnnciuGOM8kXsT"
-const prodClientID = "oB2RkLUylDTrsSxVa6qdLR3DQMbdh9IR"
+var noBrowserFlag bool
+var withTokenFlag bool
+var apiURLFlag string
 
-var clientID string
-var auth0BaseURL string
-
-func userAPIPostForm(tokens auth.Auth) {
-	var apiURL string
-	if devFlag {
-		apiURL = "https://api.dev.featurepeek.com/api/v1/user"
-	} else {
-		apiURL = "https://api.featurepeek.com/api/v1/user"
-	}
-
-	request, err := http.NewRequest("POST", apiURL, strings.NewReader(""))
-	request.Header.Add("authorization", fmt.Sprintf("Bearer %s", tokens.AccessToken))
-	request.Header.Add("X-FEATUREPEEK-CLIENT", Version)
-	if debugFlag {
-		fmt.Printf("%+v\n", request)
-	}
-
-	response, err := http.DefaultClient.Do(request)
-	if err != nil {
-		log.Fatal(err)
-	}
-
-	if response.StatusCode != http.StatusOK &&
-		response.StatusCode != http.StatusCreated {
-		log.Fatalf("\nCall to FeaturePeek API failed %d", response.StatusCode)
+// registerUser checks the logged in user in with the FeaturePeek API
+func registerUser(tokens auth.Auth, env config.Environment) {
+	client := newAPIClient(env, api.StaticToken(tokens.AccessToken))
+	if err := client.RegisterUser(); err != nil {
+		log.Fatalf("\nCall to FeaturePeek API failed: %v", err)
 	}
 }
 
-func auth0PostForm(reqPath string, data url.Values) (int, []byte, error) {
-	if devFlag {
-		clientID = devClientID
-	} else {
-		clientID = prodClientID
-	}
-	data.Set("client_id", clientID)
+func auth0PostForm(env config.Environment, reqPath string, data url.Values) (int, []byte, error) {
+	data.Set("client_id", env.ClientID)
 
-	if devFlag {
-		auth0BaseURL = devAuth0BaseURL
-	} else {
-		auth0BaseURL = prodAuth0BaseURL
-	}
-
-	reqURL := fmt.Sprintf("%s/oauth%s", auth0BaseURL, reqPath)
+	reqURL := fmt.Sprintf("%s/oauth%s", env.AuthURL, reqPath)
 
 	if debugFlag {
-		fmt.Printf("-Request-\nurl -> %s\ndata -> %s\n\n", reqURL, data)
+		fmt.Printf("-Request-\nurl -> %s\ndata -> %s\n\n", reqURL, redactForm(data))
 	}
 
-	res, err := http.DefaultClient.PostForm(reqURL, data)
+	res, err := auth0Client().PostForm(reqURL, data)
 	if err != nil {
 		return 0, nil, err
 	}
@@ -87,150 +58,284 @@ func auth0PostForm(reqPath string, data url.Values) (int, []byte, error) {
 	}
 
 	if debugFlag {
-		fmt.Printf("-Response-\nObj -> %+v\nbody -> %s\n\n", *res, body)
+		fmt.Printf("-Response-\nObj -> %+v\nbody -> %s\n\n", *res, redactJSON(body))
 	}
 
 	return res.StatusCode, body, nil
 }
 
-func auth0Get(reqPath string) ([]byte, error) {
-	if devFlag {
-		auth0BaseURL = devAuth0BaseURL
-	} else {
-		auth0BaseURL = prodAuth0BaseURL
+// secretFields are the Auth0 form and response fields left out of --debug output
+var secretFields = []string{"access_token", "refresh_token", "id_token", "token", "device_code", "client_secret"}
+
+// redactForm returns a copy of a form with its secret fields masked
+func redactForm(data url.Values) url.Values {
+	redacted := url.Values{}
+	for k, v := range data {
+		redacted[k] = v
+	}
+	for _, field := range secretFields {
+		if redacted.Get(field) != "" {
+			redacted.Set(field, "[redacted]")
+		}
+	}
+	return redacted
+}
+
+// redactJSON masks the secret fields of a JSON object, returning other bodies unchanged
+func redactJSON(body []byte) []byte {
+	var obj map[string]interface{}
+	if json.Unmarshal(body, &obj) != nil {
+		return body
+	}
+	for _, field := range secretFields {
+		if _, ok := obj[field]; ok {
+			obj[field] = "[redacted]"
+		}
 	}
+	redacted, err := json.Marshal(obj)
+	if err != nil {
+		return body
+	}
+	return redacted
+}
 
-	u, err := url.Parse(auth0BaseURL)
+func auth0Get(env config.Environment, reqPath string) ([]byte, error) {
+	u, err := url.Parse(env.AuthURL)
 	if err != nil {
 		return nil, err
 	}
 	u.Path = path.Join(u.Path, reqPath)
-	resp, err := http.Get(u.String())
+	resp, err := auth0Client().Get(u.String())
 	if err != nil {
 		return nil, err
 	}
 	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("GET %s failed with status %d", u, resp.StatusCode)
+	}
 	return ioutil.ReadAll(resp.Body)
 }
 
-func fileExists(filename string) bool {
-	info, err := os.Stat(filename)
-	if os.IsNotExist(err) {
-		return false
+// tokenRefresher returns a function that exchanges a refresh token for a new
+// access token using the Auth0 refresh grant
+func tokenRefresher(env config.Environment) func(refreshToken string) (*auth.Auth, error) {
+	return func(refreshToken string) (*auth.Auth, error) {
+		return refreshTokens(env, refreshToken)
 	}
-	return !info.IsDir()
 }
 
-func loginCommand(cmd *cobra.Command, args []string) {
-
-	var oauthAudience string
-	if devFlag {
-		oauthAudience = "http://api.dev.featurepeek.com/api/v1/"
-	} else {
-		oauthAudience = "http://api.featurepeek.com/api/v1/"
-	}
+func refreshTokens(env config.Environment, refreshToken string) (*auth.Auth, error) {
 	data := url.Values{}
-	data.Set("scope", "offline_access")
-	data.Set("audience", oauthAudience)
+	data.Set("grant_type", "refresh_token")
+	data.Set("refresh_token", refreshToken)
 
-	statusCode, body, err := auth0PostForm("/device/code", data)
+	statusCode, body, err := auth0PostForm(env, "/token", data)
 	if err != nil {
-		log.Fatal(err)
+		return nil, err
 	}
 
 	if statusCode != http.StatusOK {
-		log.Fatalf("Auth request failed:\n%s\n", body)
+		var errResp struct {
+			Error            string `json:"error"`
+			ErrorDescription string `json:"error_description"`
+		}
+		if err = json.Unmarshal(body, &errResp); err != nil || errResp.Error == "" {
+			return nil, fmt.Errorf("token refresh failed with status %d", statusCode)
+		}
+		if errResp.Error == "invalid_grant" {
+			return nil, fmt.Errorf("your session has expired (%s)", errResp.ErrorDescription)
+		}
+		return nil, fmt.Errorf("%s: %s", errResp.Error, errResp.ErrorDescription)
 	}
 
-	var resp struct {
-		DeviceCode              string        `json:"device_code"`
-		UserCode                string        `json:"user_code"`
-		VerificationURI         string        `json:"verification_uri"`
-		ExpiresIn               int           `json:"expires_in"`
-		Interval                time.Duration `json:"interval"`
-		VerificationURIComplete string        `json:"verification_uri_complete"`
+	var tokens auth.Auth
+	if err = json.Unmarshal(body, &tokens); err != nil {
+		return nil, err
 	}
-	if err = json.Unmarshal(body, &resp); err != nil {
-		log.Fatal(err)
+	return &tokens, nil
+}
+
+// expectedClaims returns the issuer and audience an access token must have
+func expectedClaims(env config.Environment) jwt.Expected {
+	return jwt.Expected{
+		Issuer:   env.Issuer(),
+		Audience: jwt.Audience{env.Audience},
 	}
+}
 
-	// print user code that must match on auth screen
-	fmt.Println(resp.UserCode)
+// keyCache returns the Auth0 signing keys, cached in the config directory
+func keyCache(env config.Environment) *auth.KeyCache {
+	return config.NewKeyCache(env.AuthURL, func() ([]byte, error) {
+		return auth0Get(env, ".well-known/jwks.json")
+	})
+}
 
-	// launch browser to user code confirmation screen
-	open.Start(resp.VerificationURIComplete)
+// deviceFlow returns the Auth0 device authorization flow for an environment
+func deviceFlow(env config.Environment) *auth.DeviceFlow {
+	return &auth.DeviceFlow{
+		BaseURL:  env.AuthURL,
+		ClientID: env.ClientID,
+		Client:   auth0Client(),
+	}
+}
 
-	// start spinner
-	loginSpinner := spinner.New("Logging in")
-	go loginSpinner.Start()
+// loginEnvironment returns the endpoints a profile logs in to, using --api-url if it is given
+func loginEnvironment(profile string) config.Environment {
+	var p *config.Profile
+	if cfg, err := config.LoadConfig(devFlag); err == nil {
+		p = cfg.GetProfile(profile)
+	}
+	env := config.ResolveEnvironment(p, devFlag)
+	if apiURLFlag != "" {
+		env.APIURL = strings.TrimSuffix(apiURLFlag, "/")
+	}
+	return env
+}
 
-	// Grab jwks from Auth0
-	jwksBody, err := auth0Get(".well-known/jwks.json")
-	if err != nil {
-		log.Fatal(err)
+// loggedInMessage tells the user which profile they logged in to
+func loggedInMessage(profile string) string {
+	if profile == config.DefaultProfile {
+		return "Logged in to FeaturePeek"
 	}
+	return fmt.Sprintf("Logged in to FeaturePeek with profile %s\nUse it with `peek --profile %s` or `peek profile use %s`", profile, profile, profile)
+}
 
-	var jwks jose.JSONWebKeySet
-	if err = json.Unmarshal(jwksBody, &jwks); err != nil {
+// saveLoginCredentials saves the tokens to the configured credential store. Tokens
+// left in the config file by earlier versions are moved to the keyring when it is
+// available; the returned note tells the user about the move.
+func saveLoginCredentials(tokens auth.Auth, profile string) string {
+	previous, _ := config.NewFileStore(profile, devFlag).Load()
+
+	store, err := config.SaveCredentials(tokens, profile, devFlag)
+	if err != nil {
 		log.Fatal(err)
 	}
-	jwk := jwks.Keys[0]
 
-	// poll for access token
-	var tokenBody []byte
-	data = url.Values{}
-	data.Set("grant_type", "urn:ietf:params:oauth:grant-type:device_code")
-	data.Set("device_code", resp.DeviceCode)
-
-	for range time.Tick(time.Second * resp.Interval) {
-		statusCode, body, err = auth0PostForm("/token", data)
+	if apiURLFlag != "" {
+		err = config.UpdateConfig(devFlag, func(cfg *config.Config) error {
+			p := cfg.GetProfile(profile)
+			p.APIURL = strings.TrimSuffix(apiURLFlag, "/")
+			cfg.SetProfile(profile, p)
+			return nil
+		})
 		if err != nil {
 			log.Fatal(err)
 		}
+	}
 
-		if statusCode == http.StatusOK {
-			tokenBody = body
-			break
-		}
+	if _, ok := store.(*config.KeyringStore); ok && previous != nil {
+		return fmt.Sprintf("Moved saved credentials from %s to the %s\n", config.File(devFlag), store.Name())
+	}
+	return ""
+}
 
-		var errResp struct {
-			Error            string `json:"error"`
-			ErrorDescription string `json:"error_description"`
-		}
-		if err = json.Unmarshal(body, &errResp); err != nil {
-			log.Fatal(err)
+func fileExists(filename string) bool {
+	info, err := os.Stat(filename)
+	if os.IsNotExist(err) {
+		return false
+	}
+	return !info.IsDir()
+}
+
+// loginWithToken saves a personal access token or API key read from input.
+// Tokens that are JWTs are checked against the Auth0 signing keys and their
+// expiry before the token is checked in with the API.
+func loginWithToken(input io.Reader, profile string, env config.Environment) {
+	data, err := ioutil.ReadAll(input)
+	if err != nil {
+		log.Fatalf("Error reading token: %v", err)
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		log.Fatal("No token given on stdin.\nPipe a token in, e.g. `peek login --with-token < token.txt`")
+	}
+
+	tokens := auth.Auth{AccessToken: token, TokenType: "Bearer"}
+	if strings.Count(token, ".") == 2 {
+		if _, err = auth.ValidateToken(token, keyCache(env), expectedClaims(env)); err != nil {
+			log.Fatalf("Invalid token: %v", err)
 		}
+	}
+
+	// check in with the api, which rejects tokens it does not accept
+	registerUser(tokens, env)
+
+	tokens.IssuedAt = time.Now().Unix()
+	note := saveLoginCredentials(tokens, profile)
+	fmt.Println(loggedInMessage(profile))
+	fmt.Print(note)
+}
+
+func loginCommand(cmd *cobra.Command, args []string) {
+	profile := activeProfile()
+	env := loginEnvironment(profile)
+	if withTokenFlag {
+		loginWithToken(os.Stdin, profile, env)
+		return
+	}
 
-		if errResp.Error == "expired_token" || errResp.Error == "access_denied" {
-			log.Fatal(errResp.ErrorDescription)
+	// cancel the login on Ctrl-C
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+	go func() {
+		select {
+		case <-interrupt:
+			cancel()
+		case <-ctx.Done():
 		}
+	}()
+
+	flow := deviceFlow(env)
+	code, err := flow.RequestCode(ctx, env.Audience, "offline_access")
+	if err != nil {
+		log.Fatalf("Auth request failed: %v", err)
 	}
 
-	// verify jwt
-	var tokens auth.Auth
-	if err = json.Unmarshal(tokenBody, &tokens); err != nil {
-		log.Fatal(err)
+	if noBrowserFlag {
+		fmt.Printf("Open %s in a browser and confirm the code %s\n", code.VerificationURI, code.UserCode)
+		if code.VerificationURIComplete != "" {
+			fmt.Printf("or go directly to %s\n", code.VerificationURIComplete)
+		}
+	} else {
+		// print user code that must match on auth screen
+		fmt.Println(code.UserCode)
+
+		// launch browser to user code confirmation screen
+		open.Start(code.VerificationURIComplete)
 	}
 
-	object, err := jose.ParseSigned(tokens.AccessToken)
+	// start spinner
+	loginSpinner := spinner.New("Logging in")
+	go loginSpinner.Start()
+
+	// poll for access token
+	tokens, err := flow.PollToken(ctx, code)
 	if err != nil {
-		log.Fatal(err)
+		loginSpinner.Stop()
+		if ctx.Err() != nil {
+			log.Fatal("\nLogin cancelled")
+		}
+		log.Fatalf("\n%v", err)
 	}
 
-	if _, err = object.Verify(&jwk); err != nil {
-		log.Fatal(err)
+	// verify jwt
+	if _, err = auth.ValidateToken(tokens.AccessToken, keyCache(env), expectedClaims(env)); err != nil {
+		log.Fatalf("\nCould not verify access token: %v", err)
 	}
 
 	// save auth to config
-	if err = config.SaveAuthToConfigFile(tokens, devFlag); err != nil {
-		log.Fatal(err)
-	}
+	tokens.IssuedAt = time.Now().Unix()
+	note := saveLoginCredentials(*tokens, profile)
 
 	// check in with the api
-	userAPIPostForm(tokens)
+	registerUser(*tokens, env)
 
 	loginSpinner.Stop()
-	fmt.Println("Logged in to FeaturePeek")
+	fmt.Println(loggedInMessage(profile))
+	fmt.Print(note)
 }
 
 // loginCmd represents the login command
@@ -241,10 +346,33 @@ var loginCmd = &cobra.Command{
 
 This command will send the user through an authentication flow that
 will authorize the CLI on the user's behalf. If the user does not have
-a FeaturePeek account, one will be created in this flow.`,
+a FeaturePeek account, one will be created in this flow.
+
+On machines without a browser, such as over SSH, use --no-browser to print
+the confirmation URL and code instead, or pipe a personal access token or
+API key in with --with-token.
+
+Credentials are saved in the system keyring when one is available
+(secret-tool on Linux, the keychain on macOS) and in ~/.config/peek/config.json
+otherwise. Set "credential_store" in config.json to "keyring" or "file" to
+choose one; credentials from older versions move to the keyring on next login.
+
+Use --profile to log in to another account, such as a company organization,
+alongside your default one. --api-url sets the API endpoint the profile uses.
+
+Endpoints can also be set per profile in config.json with "api_url",
+"auth_url", "client_id" and "audience", or for a single command with the
+PEEK_API_URL, PEEK_AUTH_URL and PEEK_CLIENT_ID environment variables.`,
+	Example: `  peek login
+  peek login --no-browser
+  peek login --with-token < token.txt
+  peek login --profile work --api-url https://api.example.com`,
 	Run: loginCommand,
 }
 
 func init() {
+	loginCmd.Flags().BoolVar(&noBrowserFlag, "no-browser", false, "print the login URL and code instead of opening a browser")
+	loginCmd.Flags().BoolVar(&withTokenFlag, "with-token", false, "read a personal access token or API key from stdin")
+	loginCmd.Flags().StringVar(&apiURLFlag, "api-url", "", "FeaturePeek API endpoint to save for the profile")
 	rootCmd.AddCommand(loginCmd)
 }
@@ -0,0 +1,20 @@
+package cmd
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestRedactForm(t *testing.T) {
+	data := url.Values{}
+	data.Set("grant_type", "refresh_token")
+	data.Set("refresh_token", "secret")
+
+	eq(t, redactForm(data).Encode(), "grant_type=refresh_token&refresh_token=%5Bredacted%5D")
+	eq(t, data.Get("refresh_token"), "secret")
+}
+
+func TestRedactJSON(t *testing.T) {
+	eq(t, string(redactJSON([]byte(`{"access_token":"secret","expires_in":86400}`))), `{"access_token":"[redacted]","expires_in":86400}`)
+	eq(t, string(redactJSON([]byte("Unauthorized"))), "Unauthorized")
+}
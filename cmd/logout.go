@@ -1,28 +1,112 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"log"
+	"os"
+	"peek/auth"
 	"peek/config"
 
 	"github.com/spf13/cobra"
 )
 
+var allProfilesFlag bool
+
+// logoutProfile revokes a profile's refresh token at Auth0 and removes its saved
+// credentials. Revocation failures are reported but do not stop the logout, and
+// the credentials in the config file are removed even when the keyring cannot be
+// read or cleared. The returned error is set when any credentials could not be
+// removed.
+func logoutProfile(name string) (string, error) {
+	profile, err := config.LoadProfile(name, devFlag)
+	var notFound *config.ProfileNotFoundError
+	var keyringErr *config.KeyringError
+	result := "no refresh token to revoke"
+	switch {
+	case errors.As(err, &notFound):
+		return "", err
+	case errors.As(err, &keyringErr):
+		result = fmt.Sprintf("could not read credentials from the keyring: %v", err)
+	case err != nil:
+		return "", fmt.Errorf("error reading credentials: %v", err)
+	case profile.Auth == nil:
+		return "not logged in", nil
+	}
+
+	if profile.Auth != nil && profile.Auth.RefreshToken != "" {
+		env := environment(profile)
+		result = "refresh token revoked"
+		if err := auth.RevokeToken(context.Background(), env.AuthURL, env.ClientID, auth0Client(), profile.Auth.RefreshToken); err != nil {
+			result = fmt.Sprintf("could not revoke refresh token: %v", err)
+		}
+	}
+
+	err = config.RemoveAuthFromConfigFile(name, devFlag)
+	if errors.As(err, &keyringErr) {
+		// the config file was cleared; only the keyring entry is left behind
+		return result, err
+	} else if err != nil {
+		return result, fmt.Errorf("error removing credentials: %v", err)
+	}
+	return result, nil
+}
+
+func logoutCommand(cmd *cobra.Command, args []string) {
+	names := []string{activeProfile()}
+	if allProfilesFlag {
+		cfg, err := config.LoadConfig(devFlag)
+		if os.IsNotExist(err) {
+			cfg = &config.Config{}
+		} else if err != nil {
+			log.Fatalf("Error reading config file: %v", err)
+		}
+		names = cfg.ProfileNames()
+	}
+
+	failed := false
+	for _, name := range names {
+		if len(names) == 1 && name == config.DefaultProfile {
+			fmt.Print("Logging out... ")
+		} else {
+			fmt.Printf("Logging out of profile %s... ", name)
+		}
+
+		result, err := logoutProfile(name)
+		if err != nil {
+			failed = true
+			fmt.Println("failed")
+			if result != "" {
+				fmt.Fprintf(os.Stderr, "  %s\n", result)
+			}
+			fmt.Fprintf(os.Stderr, "  Error: %v\n", err)
+			continue
+		}
+		fmt.Printf("done (%s)\n", result)
+	}
+
+	if failed {
+		os.Exit(1)
+	}
+}
+
 // logoutCmd represents the logout command
 var logoutCmd = &cobra.Command{
 	Use:   "logout",
 	Short: "Logout of your FeaturePeek Account",
 	Long: `Logout of your FeaturePeek Account.
 
-	This command erases your FeaturePeek account credentials
-	from your computer. You will need to log back in to launch previews
-	using the command-line tool.`,
-	Run: func(cmd *cobra.Command, args []string) {
-		fmt.Print("Logging out... ")
-		config.RemoveAuthFromConfigFile(devFlag)
-		fmt.Print("done\n")
-	},
+	This command revokes your refresh token with FeaturePeek's login service
+	and erases your FeaturePeek account credentials from your computer. You
+	will need to log back in to launch previews using the command-line tool.
+
+	Use --all-profiles to log out of every profile at once.`,
+	Args: cobra.NoArgs,
+	Run:  logoutCommand,
 }
 
 func init() {
+	logoutCmd.Flags().BoolVar(&allProfilesFlag, "all-profiles", false, "log out of every profile")
 	rootCmd.AddCommand(logoutCmd)
 }
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"peek/git"
+	"peek/manifest"
+	"peek/peekconfig"
+
+	"github.com/spf13/cobra"
+)
+
+var manifestJSONFlag bool
+var manifestOutput string
+
+// manifestCmd represents the manifest command
+var manifestCmd = &cobra.Command{
+	Use:   "manifest [directory]",
+	Short: "Print the file manifest of a build",
+	Long: `Print the file manifest of a build.
+
+Each line lists a file's SHA-256, size, mode and path relative to the build
+directory, sorted by path. This is the same manifest that is sent with each
+deployment, so the output of two builds can be compared with diff.
+
+Without a directory, the static service from peek.yml is used.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var dir string
+		if len(args) > 0 {
+			dir = args[0]
+		} else {
+			rootDir, err := git.ToplevelDir()
+			if err != nil {
+				log.Fatal(err)
+			}
+			serviceNames := splitServiceNames(targetService)
+			if len(serviceNames) > 1 {
+				log.Fatal("peek manifest reads a single service; pass one name to --service.")
+			}
+			service, err := peekconfig.LoadStaticServiceFromFile(filepath.Join(rootDir, "peek.yml"), firstServiceName(serviceNames))
+			if err != nil {
+				fatalPeekConfigError(err)
+			}
+			if service == nil {
+				log.Fatal("Static app configuration not found in peek.yml")
+			}
+			dir = filepath.Join(rootDir, service.Path)
+		}
+
+		m, err := manifest.Build(dir)
+		if err != nil {
+			log.Fatalf("Error reading directory: %v", err)
+		}
+
+		var out io.Writer = os.Stdout
+		if manifestOutput != "" {
+			f, err := os.Create(manifestOutput)
+			if err != nil {
+				log.Fatal(err)
+			}
+			defer f.Close()
+			out = f
+		}
+
+		if manifestJSONFlag {
+			enc := json.NewEncoder(out)
+			enc.SetIndent("", "  ")
+			err = enc.Encode(m)
+		} else {
+			_, err = m.WriteTo(out)
+		}
+		if err != nil {
+			log.Fatal(err)
+		}
+	},
+}
+
+func init() {
+	manifestCmd.Flags().BoolVar(&manifestJSONFlag, "json", false, "print the manifest as JSON")
+	manifestCmd.Flags().StringVarP(&manifestOutput, "output", "o", "", "write the manifest to a file instead of stdout")
+	rootCmd.AddCommand(manifestCmd)
+}
@@ -0,0 +1,67 @@
+package cmd
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"peek/api"
+	"peek/config"
+	"sync"
+)
+
+var proxyFlag string
+var caBundleFlag string
+var clientCertFlag string
+var clientKeyFlag string
+var insecureSkipVerifyFlag bool
+
+var sharedHTTPClient *http.Client
+var sharedHTTPClientOnce sync.Once
+
+// httpOptions merges the network flags over the "network" settings in the config file
+func httpOptions() api.HTTPOptions {
+	var opts api.HTTPOptions
+	if cfg, err := config.LoadConfig(devFlag); err == nil && cfg.Network != nil {
+		opts = api.HTTPOptions{
+			Proxy:              cfg.Network.Proxy,
+			CABundle:           cfg.Network.CABundle,
+			ClientCert:         cfg.Network.ClientCert,
+			ClientKey:          cfg.Network.ClientKey,
+			InsecureSkipVerify: cfg.Network.InsecureSkipVerify,
+		}
+	}
+
+	if proxyFlag != "" {
+		opts.Proxy = proxyFlag
+	}
+	if caBundleFlag != "" {
+		opts.CABundle = caBundleFlag
+	}
+	if clientCertFlag != "" {
+		opts.ClientCert = clientCertFlag
+	}
+	if clientKeyFlag != "" {
+		opts.ClientKey = clientKeyFlag
+	}
+	if insecureSkipVerifyFlag {
+		opts.InsecureSkipVerify = true
+	}
+	return opts
+}
+
+// httpClient returns the HTTP client shared by API and Auth0 requests
+func httpClient() *http.Client {
+	sharedHTTPClientOnce.Do(func() {
+		opts := httpOptions()
+		if opts.InsecureSkipVerify {
+			fmt.Fprintln(os.Stderr, "Warning: TLS certificate verification is disabled")
+		}
+
+		var err error
+		if sharedHTTPClient, err = api.NewHTTPClient(opts); err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+	})
+	return sharedHTTPClient
+}
@@ -0,0 +1,177 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"peek/api"
+	"peek/config"
+	"peek/upload"
+
+	"github.com/spf13/cobra"
+)
+
+var profileFlag string
+
+// activeProfile returns the name of the profile selected by --profile, PEEK_PROFILE or `peek profile use`
+func activeProfile() string {
+	cfg, err := config.LoadConfig(devFlag)
+	if err != nil {
+		cfg = nil
+	}
+	return config.ProfileName(profileFlag, cfg)
+}
+
+// environment returns the endpoints of a profile, which may be nil
+func environment(profile *config.Profile) config.Environment {
+	return config.ResolveEnvironment(profile, devFlag)
+}
+
+// newAPIClient returns a FeaturePeek API client for an environment
+func newAPIClient(env config.Environment, tokens api.TokenSource) *api.Client {
+	client := &api.Client{
+		BaseURL: env.APIURL,
+		Tokens:  tokens,
+		Version: Version,
+		HTTP:    httpClient(),
+		Retry:   upload.DefaultRetry,
+	}
+	if debugFlag {
+		client.Debug = os.Stdout
+		if ciFlag {
+			client.Debug = os.Stderr
+		}
+	}
+	return client
+}
+
+// auth0Client returns the HTTP client for Auth0 requests, which are bounded by api.DefaultTimeout
+func auth0Client() *http.Client {
+	return &http.Client{
+		Transport: httpClient().Transport,
+		Timeout:   api.DefaultTimeout,
+	}
+}
+
+// loadActiveProfile loads the selected profile, exiting if it has no credentials.
+// A keyring that cannot be read, as on a machine without a Secret Service
+// running, counts as having no credentials.
+func loadActiveProfile() (string, *config.Profile) {
+	name := activeProfile()
+	profile, err := config.LoadProfile(name, devFlag)
+	var notFound *config.ProfileNotFoundError
+	var keyringErr *config.KeyringError
+	isKeyringErr := errors.As(err, &keyringErr)
+	switch {
+	case errors.As(err, &notFound):
+		log.Fatalf("Error: %v", err)
+	case err != nil && !isKeyringErr:
+		log.Fatalf("Error reading config file: %v", err)
+	case profile.Auth == nil:
+		hint := ""
+		if isKeyringErr {
+			hint = fmt.Sprintf("\nThe system keyring could not be read (%v).\nIf no keyring service is running, set \"credential_store\": %q in %s.", err, config.StoreFile, config.File(devFlag))
+		}
+		if name == config.DefaultProfile {
+			log.Fatal("No credentials found. Run `peek login` to login with your FeaturePeek account." + hint)
+		}
+		log.Fatalf("No credentials found for profile %s. Run `peek login --profile %s` to login.%s", name, name, hint)
+	}
+	return name, profile
+}
+
+// profileCmd groups the commands that manage profiles
+var profileCmd = &cobra.Command{
+	Use:   "profile",
+	Short: "Manage FeaturePeek account profiles",
+	Long: `Manage FeaturePeek account profiles.
+
+Each profile has its own credentials and API endpoint, so you can deploy to a
+personal account and a company organization from the same machine. Create a
+profile with ` + "`peek login --profile <name>`" + ` and select it for a single command
+with --profile or the PEEK_PROFILE environment variable, or for every command
+with ` + "`peek profile use <name>`" + `.`,
+}
+
+var profileListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List profiles",
+	Args:  cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		cfg, err := config.LoadConfig(devFlag)
+		if os.IsNotExist(err) {
+			cfg = &config.Config{}
+		} else if err != nil {
+			log.Fatalf("Error reading config file: %v", err)
+		}
+
+		active := config.ProfileName(profileFlag, cfg)
+		for _, name := range cfg.ProfileNames() {
+			marker := " "
+			if name == active {
+				marker = "*"
+			}
+
+			status := "logged in"
+			profile, err := config.LoadProfile(name, devFlag)
+			var keyringErr *config.KeyringError
+			if errors.As(err, &keyringErr) {
+				status = "not logged in (keyring unavailable)"
+			} else if err != nil {
+				status = fmt.Sprintf("error: %v", err)
+			} else if profile.Auth == nil {
+				status = "not logged in"
+			}
+			fmt.Printf("%s %-16s %-14s %s\n", marker, name, status, environment(profile).APIURL)
+		}
+	},
+}
+
+var profileUseCmd = &cobra.Command{
+	Use:   "use <name>",
+	Short: "Select the profile used by default",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		name := args[0]
+		err := config.UpdateConfig(devFlag, func(cfg *config.Config) error {
+			if cfg.GetProfile(name) == nil {
+				return &config.ProfileNotFoundError{Name: name}
+			}
+			cfg.CurrentProfile = name
+			if name == config.DefaultProfile {
+				cfg.CurrentProfile = ""
+			}
+			return nil
+		})
+		if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		fmt.Printf("Using profile %s\n", name)
+	},
+}
+
+var profileRemoveCmd = &cobra.Command{
+	Use:   "remove <name>",
+	Short: "Remove a profile and its credentials",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		err := config.RemoveProfile(args[0], devFlag)
+		var keyringErr *config.KeyringError
+		if errors.As(err, &keyringErr) {
+			fmt.Printf("Removed profile %s\n", args[0])
+			log.Fatalf("Error: the keyring entry could not be removed: %v", err)
+		} else if err != nil {
+			log.Fatalf("Error: %v", err)
+		}
+		fmt.Printf("Removed profile %s\n", args[0])
+	},
+}
+
+func init() {
+	profileCmd.AddCommand(profileListCmd)
+	profileCmd.AddCommand(profileUseCmd)
+	profileCmd.AddCommand(profileRemoveCmd)
+	rootCmd.AddCommand(profileCmd)
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"peek/git"
+	"strings"
+)
+
+var pushFlag bool
+
+// remoteState is how the current branch on origin compares to the local checkout
+type remoteState struct {
+	originSha     string
+	branchMissing bool
+	configMissing bool
+	// notAhead is set when origin has commits HEAD does not, so a push would not fast-forward
+	notAhead bool
+}
+
+// readRemoteState compares origin's copy of a branch with the local HEAD at sha
+func readRemoteState(branch, sha string) remoteState {
+	var state remoteState
+	originSha, err := git.ShaForRemoteBranch(branch)
+	if err != nil || originSha == "" {
+		state.branchMissing = true
+		state.configMissing = true
+		return state
+	}
+	state.originSha = originSha
+	if originSha != sha {
+		ahead, err := git.IsAncestor(originSha, sha)
+		state.notAhead = err != nil || !ahead
+	}
+	state.configMissing = git.CheckForFileOnRemoteBranch(branch, "peek.yml") != nil
+	return state
+}
+
+// pushReason explains why the branch needs pushing before it can be deployed, or returns "" if it does not
+func (s remoteState) pushReason(branch, sha string) string {
+	switch {
+	case s.branchMissing:
+		return fmt.Sprintf("Branch %s has not been pushed to origin yet.", branch)
+	case s.notAhead:
+		return fmt.Sprintf("Branch %s is behind origin or has diverged from it.", branch)
+	case s.originSha != sha:
+		return fmt.Sprintf("Your local commits on %s have not been pushed to origin.", branch)
+	case s.configMissing:
+		return "peek.yml config not found on remote."
+	}
+	return ""
+}
+
+// canPush reports whether pushing the branch would bring origin up to date
+// with HEAD at sha: the branch is not on origin yet, or HEAD is strictly ahead
+// of it. A push cannot fix a branch that is behind or has diverged, or add a
+// peek.yml that is missing from HEAD too.
+func (s remoteState) canPush(sha string) bool {
+	return s.branchMissing || (s.originSha != sha && !s.notAhead)
+}
+
+// pushIfNeeded pushes the current branch to origin, setting its upstream, when
+// origin does not have HEAD or peek.yml. It asks first unless --push was given,
+// and exits if the branch is still not ready to deploy afterwards. A push is
+// only offered when canPush allows it.
+func pushIfNeeded(branch, sha string) {
+	state := readRemoteState(branch, sha)
+	reason := state.pushReason(branch, sha)
+	if reason == "" {
+		return
+	}
+	if !state.canPush(sha) {
+		fatalNotPushed(state, branch, sha)
+	}
+
+	if !pushFlag && !confirm(fmt.Sprintf("%s\n\nWould you like to push %s to origin now? (y/n)", reason, branch)) {
+		fatalNotPushed(state, branch, sha)
+	}
+
+	fmt.Printf("Pushing %s to origin...\n", branch)
+	if err := git.Push("origin", branch); err != nil {
+		log.Fatalf("Error: could not push %s: %v", branch, err)
+	}
+	fmt.Println()
+
+	// the push updates the remote tracking branch, so read it again
+	state = readRemoteState(branch, sha)
+	if state.pushReason(branch, sha) != "" {
+		fatalNotPushed(state, branch, sha)
+	}
+}
+
+// fatalNotPushed exits explaining what is missing from origin
+func fatalNotPushed(state remoteState, branch, sha string) {
+	switch {
+	case state.branchMissing:
+		log.Fatal("Error: the current branch is not on origin.\nPush it with `git push --set-upstream origin HEAD` or run peek with --push.")
+	case state.notAhead:
+		log.Fatalf("Error: %s is behind origin or has diverged from it.\nPull or rebase onto origin/%s, then push your changes.", branch, branch)
+	case state.originSha != sha:
+		log.Fatal("Error: local commit HEAD does not match origin.\nYou may still need to push your changes, or run peek with --push.")
+	default:
+		log.Fatal("peek.yml config not found on remote.\nMake sure to commit and push your config file")
+	}
+}
+
+// confirm asks a yes or no question, treating no answer as no
+func confirm(question string) bool {
+	fmt.Println(question)
+
+	var input string
+	for input == "" {
+		fmt.Print("--> ")
+		if _, err := fmt.Scanln(&input); err == io.EOF {
+			fmt.Println()
+			return false
+		}
+	}
+	return strings.ToLower(input)[0] == 'y'
+}
@@ -0,0 +1,88 @@
+package cmd
+
+import (
+	"reflect"
+	"testing"
+
+	"peek/test"
+)
+
+func TestReadRemoteState(t *testing.T) {
+	tests := []struct {
+		name    string
+		stub    func(cs *test.CmdStubber)
+		want    remoteState
+		reason  string
+		canPush bool
+	}{
+		{
+			name: "branch missing on origin",
+			stub: func(cs *test.CmdStubber) {
+				cs.StubError("")
+			},
+			want:    remoteState{branchMissing: true, configMissing: true},
+			reason:  "Branch feature has not been pushed to origin yet.",
+			canPush: true,
+		},
+		{
+			name: "up to date",
+			stub: func(cs *test.CmdStubber) {
+				cs.Stub("head\n")
+				cs.Stub("")
+			},
+			want: remoteState{originSha: "head"},
+		},
+		{
+			name: "up to date without peek.yml",
+			stub: func(cs *test.CmdStubber) {
+				cs.Stub("head\n")
+				cs.StubError("fatal: path 'peek.yml' does not exist in 'origin/feature'")
+			},
+			want:   remoteState{originSha: "head", configMissing: true},
+			reason: "peek.yml config not found on remote.",
+		},
+		{
+			name: "ahead of origin",
+			stub: func(cs *test.CmdStubber) {
+				cs.Stub("older\n")
+				cs.Stub("")
+				cs.Stub("")
+			},
+			want:    remoteState{originSha: "older"},
+			reason:  "Your local commits on feature have not been pushed to origin.",
+			canPush: true,
+		},
+		{
+			name: "behind or diverged",
+			stub: func(cs *test.CmdStubber) {
+				cs.Stub("other\n")
+				cs.StubError("")
+				cs.Stub("")
+			},
+			want:   remoteState{originSha: "other", notAhead: true},
+			reason: "Branch feature is behind origin or has diverged from it.",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cs, teardown := test.InitCmdStubber()
+			defer teardown()
+			tt.stub(cs)
+
+			state := readRemoteState("feature", "head")
+			eq(t, state, tt.want)
+			eq(t, state.pushReason("feature", "head"), tt.reason)
+			eq(t, state.canPush("head"), tt.canPush)
+			eq(t, cs.Count, len(cs.Stubs))
+		})
+	}
+}
+
+// Helper functions
+func eq(t *testing.T, got interface{}, expected interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected: %v, got: %v", expected, got)
+	}
+}
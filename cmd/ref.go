@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"errors"
+	"log"
+	"peek/context"
+	"peek/git"
+	"strings"
+)
+
+var refFlag string
+var shaFlag string
+
+// refRevision returns the revision given with --ref or --sha
+func refRevision() string {
+	if refFlag != "" {
+		return refFlag
+	}
+	return shaFlag
+}
+
+// tagForRef returns the tag name --ref refers to, or "" if it is not a tag
+// that exists on origin. A tag only created locally is left out, so the
+// deployment is made for the commit alone.
+func tagForRef(ref string) string {
+	tag := ""
+	if strings.HasPrefix(ref, "refs/tags/") {
+		tag = strings.TrimPrefix(ref, "refs/tags/")
+	} else if refs, err := git.ShowRefs("refs/tags/" + ref); err == nil && len(refs) > 0 {
+		tag = ref
+	}
+	if tag == "" {
+		return ""
+	}
+	if exists, err := git.RemoteTagExists("origin", tag); err != nil || !exists {
+		return ""
+	}
+	return tag
+}
+
+// resolveRefTarget fills in the commit and tag named by --ref or --sha
+func resolveRefTarget(target *deployTarget) {
+	rev := refRevision()
+	sha, err := git.ResolveCommit(rev)
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	target.sha = sha
+	if refFlag != "" {
+		target.tag = tagForRef(refFlag)
+	}
+}
+
+// refTarget fills in the commit named by --ref or --sha. Rather than requiring
+// a branch whose tip on origin is HEAD, the commit must be checked out, so the
+// build uses its files, and be in the history of a branch on origin.
+func refTarget(target *deployTarget) {
+	resolveRefTarget(target)
+	rev := refRevision()
+
+	head, err := git.CurrentSha()
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+	if head != target.sha {
+		log.Fatalf("Error: %s is not checked out.\nRun `git checkout %s` first so the deployment is built from it.", rev, rev)
+	}
+
+	branches, err := git.RemoteBranchesContaining("origin", target.sha)
+	if err != nil {
+		log.Fatalf("Error reading remote branches: %v", err)
+	}
+	if len(branches) == 0 {
+		log.Fatalf("Error: commit %s is not on any branch of origin.\nPush it, or run `git fetch origin` if it has been pushed.", shortSha(target.sha))
+	}
+	target.branch = containingBranch(branches)
+
+	if git.CheckForFileInCommit(target.sha, "peek.yml") != nil {
+		log.Fatalf("peek.yml config not found in commit %s.\nDeploy a commit that includes your config file.", shortSha(target.sha))
+	}
+
+	// warn for uncommited files
+	uncommitedFiles, err := git.GetUncommitedFiles()
+	if err != nil {
+		log.Fatal("Error reading git status")
+	}
+	if len(uncommitedFiles) > 0 {
+		showUncommitedChangesWarning()
+	}
+
+	target.org, target.repo = originRepo()
+}
+
+// containingBranch picks the branch to report for a commit from the origin
+// branches that contain it: the one named by --ref, then the checked out
+// branch, then the first
+func containingBranch(branches []string) string {
+	current, _ := git.CurrentBranch()
+	for _, name := range []string{refFlag, current} {
+		for _, branch := range branches {
+			if name != "" && branch == name {
+				return branch
+			}
+		}
+	}
+	return branches[0]
+}
+
+// originRepo returns the owner and name of the repository on the origin remote
+func originRepo() (string, string) {
+	remotes, err := context.GetRemotes()
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
+
+	originRemote, err := remotes.FindByName("origin")
+	if err != nil {
+		log.Fatal("Error: no remote named 'origin' found")
+	}
+	return originRemote.Owner, originRemote.Repo
+}
+
+func shortSha(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
+
+// errDetachedHead explains how to deploy a commit that is not on a branch
+var errDetachedHead = errors.New("HEAD is not on a branch.\nUse --ref <tag> or --sha <commit> to deploy the checked out commit.")
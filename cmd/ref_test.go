@@ -0,0 +1,27 @@
+package cmd
+
+import (
+	"testing"
+
+	"peek/test"
+)
+
+func TestTagForRef(t *testing.T) {
+	cs, teardown := test.InitCmdStubber()
+	defer teardown()
+
+	// a local tag that is also on origin
+	cs.Stub("0123456789abcdef refs/tags/v1.4.0\n")
+	cs.Stub("0123456789abcdef\trefs/tags/v1.4.0\n")
+	eq(t, tagForRef("v1.4.0"), "v1.4.0")
+
+	// a tag that was never pushed
+	cs.Stub("")
+	eq(t, tagForRef("refs/tags/v1.5.0-local"), "")
+
+	// a branch or commit
+	cs.StubError("fatal: 'refs/tags/main' - not a valid ref")
+	eq(t, tagForRef("main"), "")
+
+	eq(t, cs.Count, 4)
+}
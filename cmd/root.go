@@ -2,29 +2,25 @@
 package cmd
 
 import (
-	"bytes"
-	"crypto/md5"
-	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
 	"log"
 	"math/rand"
-	"mime/multipart"
-	"net/http"
 	"os"
 	"path/filepath"
+	"peek/api"
 	"peek/config"
-	"peek/context"
 	"peek/git"
 	"peek/peekconfig"
 	"peek/spinner"
+	"peek/upload"
 	"runtime/debug"
 	"strings"
 	"time"
 
-	"github.com/mholt/archiver/v3"
 	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
 )
 
 // Version is dynamically set by the toolchain.
@@ -44,15 +40,36 @@ func init() {
 	versionOutput = fmt.Sprintf("peek version %s", rootCmd.Version)
 
 	rootCmd.PersistentFlags().StringVar(&targetDir, "dir", "", "target directory to launch from")
-	rootCmd.PersistentFlags().StringVar(&targetService, "service", "", "select specific front-end service to launch")
+	rootCmd.PersistentFlags().StringVar(&targetService, "service", "", "select specific front-end services to launch, separated by commas")
+	rootCmd.PersistentFlags().StringVar(&profileFlag, "profile", "", "use the credentials and API endpoint of a named profile (or set PEEK_PROFILE)")
 	rootCmd.PersistentFlags().BoolVar(&debugFlag, "debug", false, "debug output")
+	rootCmd.PersistentFlags().StringVar(&proxyFlag, "proxy", "", "proxy URL for all requests (defaults to HTTPS_PROXY)")
+	rootCmd.PersistentFlags().StringVar(&caBundleFlag, "ca-bundle", "", "PEM file of extra certificate authorities to trust")
+	rootCmd.PersistentFlags().StringVar(&clientCertFlag, "client-cert", "", "PEM client certificate for mutual TLS")
+	rootCmd.PersistentFlags().StringVar(&clientKeyFlag, "client-key", "", "PEM private key for --client-cert")
+	rootCmd.PersistentFlags().BoolVar(&insecureSkipVerifyFlag, "insecure-skip-verify", false, "do not verify TLS certificates (local testing only)")
 	rootCmd.PersistentFlags().BoolVar(&devFlag, "dev", false, "dev use")
 	rootCmd.PersistentFlags().MarkHidden("dev")
+	addDeployFlags(rootCmd.Flags())
 	rootCmd.Flags().Bool("version", false, "Show peek version")
 
 	rootCmd.Flags().BoolP("toggle", "t", false, "Help message for toggle")
 }
 
+// addDeployFlags adds the flags shared by `peek` and `peek deploy`
+func addDeployFlags(flags *pflag.FlagSet) {
+	flags.BoolVar(&allFlag, "all", false, "launch every static service in peek.yml")
+	flags.BoolVar(&noBuildFlag, "no-build", false, "skip the build commands in peek.yml")
+	flags.BoolVar(&contentLengthFlag, "content-length", false, "send a Content-Length header with the upload (packages assets twice)")
+	flags.BoolVar(&chunkedFlag, "chunked", false, "upload in resumable chunks, retrying failed chunks")
+	flags.BoolVar(&incrementalFlag, "incremental", false, "upload only files that changed since previous deployments")
+	flags.Int64Var(&chunkSizeFlag, "chunk-size", upload.DefaultChunkSize>>20, "chunk size in MiB for --chunked uploads")
+	flags.BoolVar(&ciFlag, "ci", false, "deploy from a CI pipeline: no prompts, token from PEEK_TOKEN, JSON output")
+	flags.StringVar(&refFlag, "ref", "", "deploy the checked out tag or ref instead of the current branch")
+	flags.StringVar(&shaFlag, "sha", "", "deploy the checked out commit instead of the current branch")
+	flags.BoolVar(&pushFlag, "push", false, "push the current branch to origin first if origin is behind")
+}
+
 var versionCmd = &cobra.Command{
 	Use:    "version",
 	Hidden: true,
@@ -70,15 +87,27 @@ The goal of this tool is to create deployment previews for any commit, all witho
 
 To get started, simply run ` + "`peek login`" + `to authenticate locally and/or create an account.
 Run ` + "`peek init`" + ` and enter your build directory to set up your config.
-Make sure your code pushed to your remote and run your build step.
-Then run ` + "`peek`" + ` to launch your FeaturePeek deployment.`
+Make sure your code is pushed to your remote (or pass --push) and run your build step,
+or add a ` + "`build`" + ` command to your service in peek.yml to have peek run it for you.
+Then run ` + "`peek`" + ` to launch your FeaturePeek deployment.
+
+Behind a corporate proxy or TLS-inspecting firewall, use --proxy, --ca-bundle,
+--client-cert and --client-key, or set them once under "network" in
+~/.config/peek/config.json as "proxy", "ca_bundle", "client_cert" and "client_key".`
 
 const errorMessageCI = `CI environment detected.
-The peek CLI is meant to be used interactively at the command line.
+Run ` + "`peek deploy --ci`" + ` to deploy from your CI pipeline without prompts.
 
-To use FeaturePeek in your CI pipeline, sign up for FeaturePeek Teams at https://featurepeek.com/product/teams`
+CI mode reads an access token from PEEK_TOKEN and the branch, commit and pull
+request from your CI provider, and prints the results as JSON.`
 
 var debugFlag bool
+var contentLengthFlag bool
+var allFlag bool
+var noBuildFlag bool
+var chunkedFlag bool
+var chunkSizeFlag int64
+var incrementalFlag bool
 var devFlag bool
 var targetDir string
 var targetService string
@@ -88,214 +117,165 @@ var rootCmd = &cobra.Command{
 	Use:   "peek",
 	Short: "FeaturePeek Command-line Tool",
 	Long:  peekCommandLongDesc,
-	Run: func(cmd *cobra.Command, args []string) {
-		var err error
-
-		// check if running in CI
-		if os.Getenv("CI") != "" {
-			log.Fatalln(errorMessageCI)
-		}
-
-		if targetDir != "" {
-			currentDir, err := os.Getwd()
-			if err != nil {
-				log.Fatalf("Could not get current directory: %v", err)
-			}
-			if err = os.Chdir(targetDir); err != nil {
-				log.Fatalf("Could not open target directory: %v", err)
-			}
-			defer os.Chdir(currentDir)
-		}
+	Run:   deployCommand,
+}
 
-		// Load auth and config files
-		localConfig, err := config.LoadConfig(devFlag)
-		if err != nil {
-			if os.IsNotExist(err) {
-				log.Fatal("No credentials found. Run `peek login` to login with your FeaturePeek account.")
-			} else {
-				log.Fatalf("Error reading config file: %v", err)
-			}
-		}
+// deployCommand builds and uploads the services in peek.yml for the current commit
+func deployCommand(cmd *cobra.Command, args []string) {
+	var err error
 
-		tokens := localConfig.Auth
-		if tokens == nil {
-			log.Fatal("No credentials found. Run `peek login` to login with your FeaturePeek account.")
-		}
+	if chunkedFlag && incrementalFlag {
+		log.Fatal("--chunked and --incremental cannot be used together")
+	}
+	if allFlag && targetService != "" {
+		log.Fatal("--all and --service cannot be used together")
+	}
+	if refFlag != "" && shaFlag != "" {
+		log.Fatal("--ref and --sha cannot be used together")
+	}
+	if pushFlag && (ciFlag || refRevision() != "") {
+		log.Fatal("--push cannot be used with --ci, --ref or --sha")
+	}
 
-		rootDir, err := git.ToplevelDir()
-		if err != nil {
-			log.Fatal(err)
-		}
+	// check if running in CI; only --ci reads the build from the provider
+	if os.Getenv("CI") != "" && !ciFlag {
+		log.Fatalln(errorMessageCI)
+	}
 
-		peekConfigFilename := filepath.Join(rootDir, "peek.yml")
-		service, err := peekconfig.LoadStaticServiceFromFile(peekConfigFilename, targetService)
+	if targetDir != "" {
+		currentDir, err := os.Getwd()
 		if err != nil {
-			if os.IsNotExist(err) {
-				log.Fatal("No peek.yml config found.\n\nRun `peek init` to create one!")
-			} else {
-				log.Fatalf("Cannot read peek.yml config: %v.", err)
-			}
+			log.Fatalf("Could not get current directory: %v", err)
 		}
-		if service == nil {
-			log.Fatal("Static app configuration not found in peek.yml")
+		if err = os.Chdir(targetDir); err != nil {
+			log.Fatalf("Could not open target directory: %v", err)
 		}
+		defer os.Chdir(currentDir)
+	}
 
-		assetPath := filepath.Join(rootDir, service.Path)
-
-		// Read info out of local git repo
-		branch, err := git.CurrentBranch()
-		if err != nil {
-			log.Fatalf("Error: %v", err)
-		}
+	// Load auth and config files
+	var env config.Environment
+	var tokens api.TokenSource
+	if ciFlag {
+		env, tokens = ciTokens()
+	} else {
+		profileName, profile := loadActiveProfile()
+		env = environment(profile)
+		tokens = config.NewTokenManager(profile.Auth, profileName, devFlag, tokenRefresher(env))
+	}
 
-		// make sure peek config exists on remote
-		err = git.CheckForFileOnRemoteBranch(branch, "peek.yml")
-		if err != nil {
-			log.Fatal("peek.yml config not found on remote.\nMake sure to push your config file")
-		}
+	rootDir, err := git.ToplevelDir()
+	if err != nil {
+		log.Fatal(err)
+	}
 
-		// warn for uncommited files
-		uncommitedFiles, err := git.GetUncommitedFiles()
-		if err != nil {
-			log.Fatal("Error reading git status")
-		}
-		if len(uncommitedFiles) > 0 {
-			showUncommitedChangesWarning()
+	peekConfigFilename := filepath.Join(rootDir, "peek.yml")
+	var services []*peekconfig.SimpleService
+	serviceNames := splitServiceNames(targetService)
+	if allFlag || len(serviceNames) > 1 {
+		services, err = peekconfig.LoadStaticServicesFromFile(peekConfigFilename, serviceNames)
+	} else {
+		var service *peekconfig.SimpleService
+		service, err = peekconfig.LoadStaticServiceFromFile(peekConfigFilename, firstServiceName(serviceNames))
+		if service != nil {
+			services = append(services, service)
 		}
+	}
+	if err != nil {
+		fatalPeekConfigError(err)
+	}
+	if len(services) == 0 {
+		log.Fatal("Static app configuration not found in peek.yml")
+	}
 
-		sha, err := git.CurrentSha()
-		if err != nil {
-			log.Fatalf("Error: %v", err)
-		}
+	target := deployTarget{rootDir: rootDir}
+	if ciFlag {
+		ciTarget(&target)
+	} else {
+		localTarget(&target)
+	}
 
-		originSha, err := git.ShaForRemoteBranch(branch)
-		if err != nil {
-			log.Fatalf("Error reading remote branch: %v", err)
-		}
+	// make sure the access token is usable before building and packaging anything
+	if _, err = tokens.AccessToken(); err != nil {
+		log.Fatalf("Error: %v\nRun `peek login` to login again.", err)
+	}
+	target.client = newAPIClient(env, tokens)
 
-		if originSha != sha {
-			log.Fatal("Error: local commit HEAD does not match origin.\nYou may still need to push your changes.")
+	if !noBuildFlag {
+		// keep stdout for the JSON results in CI
+		buildOutput := io.Writer(os.Stdout)
+		if ciFlag {
+			buildOutput = os.Stderr
 		}
-
-		remotes, err := context.GetRemotes()
-		if err != nil {
+		if err = buildServices(services, rootDir, buildOutput); err != nil {
 			log.Fatalf("Error: %v", err)
 		}
+	}
 
-		originRemote, err := remotes.FindByName("origin")
-		if err != nil {
-			log.Fatal("Error: no remote named 'origin' found")
-		}
-
-		org := originRemote.Owner
-		repo := originRemote.Repo
-
-		// Archive web asset directory
-		files, err := ioutil.ReadDir(assetPath)
-		if err != nil {
-			log.Fatalf("Error reading directory: %v", err)
-		}
-
-		var fileNames []string
-		for _, file := range files {
-			fileNames = append(fileNames, filepath.Join(assetPath, file.Name()))
-		}
-
-		checksum, err := dirChecksum(assetPath)
-		if err != nil {
-			log.Fatalf("Error reading directory: %v", err)
+	if ciFlag {
+		if failed := printCIResults(target, deployServices(services, target)); failed > 0 {
+			os.Exit(1)
 		}
+		return
+	}
 
-		// Send ping
-		uploadSpinner := spinner.New("Packaging and Uploading")
-		go uploadSpinner.Start()
-
-		body := &bytes.Buffer{}
-		writer := multipart.NewWriter(body)
-		part, err := writer.CreateFormFile("artifacts", "artifacts.tar.gz")
-		if err != nil {
-			log.Fatal(err)
-		}
+	// Send ping
+	uploadSpinner := spinner.New("Packaging and Uploading")
+	go uploadSpinner.Start()
 
-		tmpDir := os.TempDir()
-		tmpFilename := filepath.Join(tmpDir, "artifacts.tar.gz")
+	results := deployServices(services, target)
 
-		err = archiver.Archive(fileNames, tmpFilename)
-		if err != nil {
-			log.Fatal(err)
-		}
+	uploadSpinner.Stop()
 
-		file, err := os.Open(tmpFilename)
-		if err != nil {
-			log.Fatal(err)
-		}
-		defer file.Close()
-		_, err = io.Copy(part, file)
-		os.Remove(tmpFilename)
-
-		writer.WriteField("app", service.Name)
-		writer.WriteField("service", "cli")
-		writer.WriteField("org", org)
-		writer.WriteField("repo", repo)
-		writer.WriteField("sha", sha)
-		writer.WriteField("branch", branch)
-		writer.WriteField("checksum", checksum)
-		if err = writer.Close(); err != nil {
-			log.Fatal(err)
+	if len(results) == 1 {
+		if results[0].err != nil {
+			log.Fatal(results[0].err)
 		}
+		printDeployResult(results[0])
+		return
+	}
 
-		var pingURL string
-		if devFlag {
-			pingURL = "https://api.dev.featurepeek.com/api/v1/peek"
-		} else {
-			pingURL = "https://api.featurepeek.com/api/v1/peek"
-		}
-		request, err := http.NewRequest("POST", pingURL, body)
-		request.Header.Add("authorization", fmt.Sprintf("Bearer %s", tokens.AccessToken))
-		request.Header.Add("X-FEATUREPEEK-CLIENT", Version)
-		request.Header.Set("Content-Type", writer.FormDataContentType())
-		if debugFlag {
-			fmt.Printf("%+v\n", request)
-		}
+	if failed := printDeploySummary(results); failed > 0 {
+		os.Exit(1)
+	}
+}
 
-		response, err := http.DefaultClient.Do(request)
-		if err != nil {
-			log.Fatal(err)
-		}
-		defer response.Body.Close()
+// localTarget fills in the checked out branch and commit, making sure both it
+// and peek.yml have been pushed to origin
+func localTarget(target *deployTarget) {
+	if refRevision() != "" {
+		refTarget(target)
+		return
+	}
 
-		resBody, err := ioutil.ReadAll(response.Body)
-		if err != nil {
-			log.Fatal(err)
-		}
+	// Read info out of local git repo
+	branch, err := git.CurrentBranch()
+	if errors.Is(err, git.ErrNotOnBranch) {
+		log.Fatalf("Error: %v", errDetachedHead)
+	} else if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
 
-		uploadSpinner.Stop()
+	// warn for uncommited files
+	uncommitedFiles, err := git.GetUncommitedFiles()
+	if err != nil {
+		log.Fatal("Error reading git status")
+	}
+	if len(uncommitedFiles) > 0 {
+		showUncommitedChangesWarning()
+	}
 
-		if debugFlag {
-			fmt.Println(response.StatusCode)
-			fmt.Println(response.Header)
-		}
+	sha, err := git.CurrentSha()
+	if err != nil {
+		log.Fatalf("Error: %v", err)
+	}
 
-		if response.StatusCode != http.StatusOK && response.StatusCode != http.StatusCreated {
-			var errorResponse struct {
-				Errors []string
-			}
-			if err = json.Unmarshal(resBody, &errorResponse); err != nil {
-				if len(resBody) == 0 {
-					log.Fatalf("Upload failed with status %d", response.StatusCode)
-				}
-				log.Fatalf("Upload Failed with status %d - %s", response.StatusCode, string(resBody))
-			}
-			log.Fatalf("Upload Failed with status %d - %s", response.StatusCode, errorResponse.Errors)
-		}
+	// make sure HEAD and peek config exist on remote, offering to push them
+	pushIfNeeded(branch, sha)
 
-		if response.StatusCode == http.StatusOK {
-			fmt.Println(string(resBody))
-		} else {
-			fmt.Printf("Assets uploaded successfully! %s\n", randomEmoji())
-			fmt.Printf("Visit your deployment preview here: %s\n", string(resBody))
-		}
-	},
+	target.org, target.repo = originRepo()
+	target.sha = sha
+	target.branch = branch
 }
 
 func randomEmoji() string {
@@ -316,44 +296,25 @@ func Execute() {
 	}
 }
 
-func dirChecksum(dir string) (string, error) {
-	hashdump := md5.New()
-	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-		if err != nil {
-			return err
-		}
-		if !info.Mode().IsRegular() {
-			return nil
-		}
-
-		data, err := ioutil.ReadFile(path)
-		if err != nil {
-			return err
-		}
-		io.WriteString(hashdump, string(data))
-		return nil
-	})
-
-	if err != nil {
-		return "", err
+// fatalPeekConfigError exits with an explanation of why services could not be loaded from peek.yml
+func fatalPeekConfigError(err error) {
+	switch err.(type) {
+	case *peekconfig.ServiceNotFoundError, *peekconfig.AmbiguousServiceError:
+		log.Fatalf("Error: %v", err)
+	case peekconfig.ValidationErrors:
+		log.Fatalf("Invalid peek.yml config:\n%v", err)
 	}
-
-	return fmt.Sprintf("%x", hashdump.Sum(nil)), nil
+	if os.IsNotExist(err) {
+		log.Fatal("No peek.yml config found.\n\nRun `peek init` to create one!")
+	}
+	log.Fatalf("Cannot read peek.yml config: %v.", err)
 }
 
 func showUncommitedChangesWarning() {
-	var input string
-
 	fmt.Println("You have local uncommited changes.")
 	fmt.Println("\nIf they effect your deployment,\nthey will not be visible on your remote until you commit and push them.")
-	fmt.Println("\nWould you like to continue anyway? (y/n)")
-
-	for input == "" {
-		fmt.Print("--> ")
-		fmt.Scanln(&input)
-	}
 
-	if strings.ToLower(input)[0] != 'y' {
+	if !confirm("\nWould you like to continue anyway? (y/n)") {
 		os.Exit(0)
 	}
 }
@@ -0,0 +1,231 @@
+package cmd
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"peek/auth"
+	"peek/config"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// Exit codes for `peek auth status`
+const (
+	exitNotLoggedIn      = 2
+	exitTokenExpired     = 3
+	exitInvalidSignature = 4
+)
+
+var statusJSONFlag bool
+
+// authStatus is the machine-readable result of `peek auth status`
+type authStatus struct {
+	LoggedIn          bool       `json:"logged_in"`
+	Profile           string     `json:"profile"`
+	Environment       string     `json:"environment"`
+	APIURL            string     `json:"api_url"`
+	Email             string     `json:"email,omitempty"`
+	Org               string     `json:"org,omitempty"`
+	Subject           string     `json:"subject,omitempty"`
+	Audience          []string   `json:"audience,omitempty"`
+	Issuer            string     `json:"issuer,omitempty"`
+	ExpiresAt         *time.Time `json:"expires_at,omitempty"`
+	Expired           bool       `json:"expired"`
+	CanRefresh        bool       `json:"can_refresh"`
+	SignatureVerified bool       `json:"signature_verified"`
+	Error             string     `json:"error,omitempty"`
+}
+
+func authStatusCommand(cmd *cobra.Command, args []string) {
+	status := authStatus{Profile: activeProfile()}
+
+	exitCode := 0
+	profile, err := config.LoadProfile(status.Profile, devFlag)
+	env := environment(profile)
+	status.Environment = env.Name
+	status.APIURL = env.APIURL
+	var notFound *config.ProfileNotFoundError
+	var keyringErr *config.KeyringError
+	switch {
+	case errors.As(err, &keyringErr):
+		status.Error = err.Error()
+		exitCode = exitNotLoggedIn
+	case err != nil && !errors.As(err, &notFound):
+		log.Fatalf("Error reading config file: %v", err)
+	case err != nil || profile.Auth == nil:
+		exitCode = exitNotLoggedIn
+	default:
+		exitCode = inspectTokens(profile.Auth, env, &status)
+	}
+
+	if statusJSONFlag {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(status); err != nil {
+			log.Fatal(err)
+		}
+	} else {
+		printAuthStatus(status)
+	}
+
+	os.Exit(exitCode)
+}
+
+// inspectTokens fills in status from the stored tokens and returns the exit code
+func inspectTokens(tokens *auth.Auth, env config.Environment, status *authStatus) int {
+	status.LoggedIn = true
+	status.CanRefresh = tokens.RefreshToken != ""
+
+	if strings.Count(tokens.AccessToken, ".") != 2 {
+		// API keys saved with `peek login --with-token` are opaque and cannot be inspected locally
+		status.Subject = "API key"
+		return 0
+	}
+
+	claims, err := auth.ParseClaims(tokens.AccessToken)
+	if err != nil {
+		status.Error = fmt.Sprintf("stored access token is malformed: %v", err)
+		return exitInvalidSignature
+	}
+
+	var idClaims *auth.Claims
+	if tokens.IDToken != "" {
+		idClaims, _ = auth.ParseClaims(tokens.IDToken)
+	}
+
+	status.Subject = claims.Subject
+	status.Audience = claims.Audience
+	status.Issuer = claims.Issuer
+	status.Email = firstNonEmpty(idClaims.Value("email"), claims.Value("email"))
+	status.Org = firstNonEmpty(idClaims.Value("org"), claims.Value("org"))
+	if expiresAt := tokens.ExpiresAt(); !expiresAt.IsZero() {
+		status.ExpiresAt = &expiresAt
+	}
+	status.Expired = tokens.Expired(time.Now(), 0)
+
+	// the signature is checked before the claims, so an expired token has a valid signature
+	_, err = auth.ValidateToken(tokens.AccessToken, keyCache(env), expectedClaims(env))
+	switch {
+	case err == nil:
+		status.SignatureVerified = true
+	case errors.Is(err, auth.ErrTokenExpired):
+		status.SignatureVerified = true
+		status.Expired = true
+	default:
+		status.Error = fmt.Sprintf("could not verify token: %v", err)
+		return exitInvalidSignature
+	}
+
+	if status.Expired {
+		return exitTokenExpired
+	}
+	return 0
+}
+
+func printAuthStatus(status authStatus) {
+	if !status.LoggedIn {
+		fmt.Printf("Not logged in to FeaturePeek (%s).\nRun `peek login%s` to login with your FeaturePeek account.\n", status.Environment, profileArg(status.Profile))
+		return
+	}
+
+	who := firstNonEmpty(status.Email, status.Subject, "unknown user")
+	fmt.Printf("Logged in to FeaturePeek (%s) as %s\n", status.Environment, who)
+	if status.Profile != config.DefaultProfile {
+		fmt.Printf("  Profile:      %s\n", status.Profile)
+	}
+	fmt.Printf("  API:          %s\n", status.APIURL)
+	if status.Org != "" {
+		fmt.Printf("  Organization: %s\n", status.Org)
+	}
+	if len(status.Audience) > 0 {
+		fmt.Printf("  Audience:     %s\n", strings.Join(status.Audience, ", "))
+	}
+	switch {
+	case status.ExpiresAt == nil:
+		fmt.Println("  Expires:      unknown")
+	case status.Expired:
+		fmt.Printf("  Expires:      expired %s\n", status.ExpiresAt.Local().Format(time.RFC1123))
+	default:
+		remaining := time.Until(*status.ExpiresAt).Round(time.Minute)
+		fmt.Printf("  Expires:      %s (in %s)\n", status.ExpiresAt.Local().Format(time.RFC1123), remaining)
+	}
+	if status.SignatureVerified {
+		fmt.Println("  Signature:    verified")
+	}
+
+	if status.Error != "" {
+		fmt.Printf("\nError: %s\n", status.Error)
+	}
+	if status.Expired {
+		if status.CanRefresh {
+			fmt.Println("\nThe access token has expired and will be refreshed on your next deploy.")
+		} else {
+			fmt.Printf("\nThe access token has expired. Run `peek login%s` to login again.\n", profileArg(status.Profile))
+		}
+	}
+}
+
+// profileArg returns the --profile argument to suggest in commands for a profile
+func profileArg(profile string) string {
+	if profile == config.DefaultProfile {
+		return ""
+	}
+	return " --profile " + profile
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+const authStatusLongDesc = `Show which FeaturePeek account the CLI is logged in as.
+
+The stored tokens are decoded to show the account's email, organization,
+audience and expiry, and the token signature is checked against the cached
+Auth0 signing keys and its issuer and audience are checked.
+
+Exit codes:
+  0  logged in with a valid token
+  2  not logged in
+  3  the access token has expired
+  4  the token could not be verified`
+
+// authCmd groups the commands that manage CLI credentials
+var authCmd = &cobra.Command{
+	Use:   "auth",
+	Short: "Manage FeaturePeek authentication",
+}
+
+// authStatusCmd represents the auth status command
+var authStatusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "Show the logged in FeaturePeek account",
+	Long:  authStatusLongDesc,
+	Run:   authStatusCommand,
+}
+
+// whoamiCmd is a shorthand for auth status
+var whoamiCmd = &cobra.Command{
+	Use:   "whoami",
+	Short: "Show the logged in FeaturePeek account",
+	Long:  authStatusLongDesc,
+	Run:   authStatusCommand,
+}
+
+func init() {
+	for _, c := range []*cobra.Command{authStatusCmd, whoamiCmd} {
+		c.Flags().BoolVar(&statusJSONFlag, "json", false, "print status as JSON")
+	}
+	authCmd.AddCommand(authStatusCmd)
+	rootCmd.AddCommand(authCmd)
+	rootCmd.AddCommand(whoamiCmd)
+}
@@ -0,0 +1,78 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"peek/api"
+	"peek/config"
+	"peek/manifest"
+	"peek/upload"
+)
+
+// peekPath is the API path deployments are uploaded to
+const peekPath = "/api/v1/peek"
+
+// uploadStream sends the form in a single request, streaming the archive as it is packaged
+func uploadStream(form *upload.Form, client *api.Client) (*api.Response, error) {
+	return client.UploadForm(peekPath, form, contentLengthFlag)
+}
+
+// uploadChunked sends the form as a resumable chunked upload, keeping progress under the config dir
+func uploadChunked(form *upload.Form, client *api.Client) (*api.Response, error) {
+	uploader := &upload.ChunkedUploader{
+		Client:    client.HTTPClient(),
+		URL:       client.URL(peekPath),
+		Header:    client.Header,
+		ChunkSize: chunkSizeFlag << 20,
+		Retry:     upload.DefaultRetry,
+		StateDir:  filepath.Join(config.Dir(), "uploads"),
+	}
+
+	statusCode, resBody, err := uploader.Upload(form)
+	if err != nil {
+		var statusErr *upload.StatusError
+		if errors.As(err, &statusErr) {
+			return nil, api.NewAPIError(statusErr.StatusCode, statusErr.Body)
+		}
+		return nil, fmt.Errorf("Upload failed: %v\nRun `peek` again to resume the upload.", err)
+	}
+
+	if debugFlag {
+		fmt.Println(statusCode)
+	}
+
+	return &api.Response{StatusCode: statusCode, Body: resBody}, nil
+}
+
+// uploadIncremental sends the build manifest and then only the files the server does not already have
+func uploadIncremental(form *upload.Form, m manifest.Manifest, client *api.Client) (*api.Response, error) {
+	uploader := &upload.IncrementalUploader{
+		Client: client.HTTPClient(),
+		URL:    client.URL(peekPath),
+		Header: client.Header,
+		Retry:  upload.DefaultRetry,
+	}
+
+	deployment, err := uploader.Start(form.Fields, m)
+	if err == nil {
+		if debugFlag {
+			fmt.Printf("\nuploading %d of %d files\n", len(deployment.Missing), len(m))
+		}
+		err = uploader.SendMissing(form.Dir, deployment)
+	}
+	var statusCode int
+	var resBody []byte
+	if err == nil {
+		statusCode, resBody, err = uploader.Complete(deployment)
+	}
+	if err != nil {
+		var statusErr *upload.StatusError
+		if errors.As(err, &statusErr) {
+			return nil, api.NewAPIError(statusErr.StatusCode, statusErr.Body)
+		}
+		return nil, fmt.Errorf("Upload failed: %v", err)
+	}
+
+	return &api.Response{StatusCode: statusCode, Body: resBody}, nil
+}
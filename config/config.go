@@ -1,11 +1,16 @@
 package config
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"io/ioutil"
 	"os"
 	"path"
+	"path/filepath"
 	"peek/auth"
+	"reflect"
+	"time"
 
 	"github.com/mitchellh/go-homedir"
 )
@@ -27,6 +32,13 @@ func File(dev bool) string {
 	return path.Join(Dir(), filename)
 }
 
+// JWKSFile returns the full path of the cached signing keys of the Auth0 tenant
+// at authURL. Each tenant gets its own file, named after a hash of its URL.
+func JWKSFile(authURL string) string {
+	sum := sha256.Sum256([]byte(authURL))
+	return path.Join(Dir(), "jwks-"+hex.EncodeToString(sum[:6])+".json")
+}
+
 // ReadConfigFile reads and returns the contents of the given file (mockable)
 var ReadConfigFile = func(filename string) ([]byte, error) {
 	f, err := os.Open(filename)
@@ -45,7 +57,22 @@ var ReadConfigFile = func(filename string) ([]byte, error) {
 
 // Config represents the CLI configuration
 type Config struct {
-	Auth *auth.Auth `json:"auth"`
+	// Profile is the default profile, kept at the top level for earlier versions
+	Profile
+	// CredentialStore selects where tokens are saved: auto, keyring or file
+	CredentialStore string              `json:"credential_store,omitempty"`
+	CurrentProfile  string              `json:"current_profile,omitempty"`
+	Profiles        map[string]*Profile `json:"profiles,omitempty"`
+	Network         *Network            `json:"network,omitempty"`
+}
+
+// Network holds the proxy and TLS settings used for every request the CLI makes
+type Network struct {
+	Proxy              string `json:"proxy,omitempty"`
+	CABundle           string `json:"ca_bundle,omitempty"`
+	ClientCert         string `json:"client_cert,omitempty"`
+	ClientKey          string `json:"client_key,omitempty"`
+	InsecureSkipVerify bool   `json:"insecure_skip_verify,omitempty"`
 }
 
 // LoadConfig will load the appropriate config given the dev flag
@@ -68,27 +95,176 @@ func ParseConfigFile(filename string) (*Config, error) {
 	return &configData, nil
 }
 
-// SaveAuthToConfigFile will marshall and save auth to the config file
-func SaveAuthToConfigFile(newAuth auth.Auth, devFlag bool) error {
+// readConfig parses the config file, returning an empty config if it does not exist
+func readConfig(filename string) (*Config, error) {
+	cfg, err := ParseConfigFile(filename)
+	if os.IsNotExist(err) {
+		return &Config{}, nil
+	}
+	return cfg, err
+}
+
+// writeConfig saves the config file, readable only by the user. An empty config removes the file.
+func writeConfig(filename string, cfg *Config) error {
+	if reflect.DeepEqual(*cfg, Config{}) {
+		err := os.Remove(filename)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
 	// create config dir
-	os.MkdirAll(Dir(), 0755)
+	os.MkdirAll(filepath.Dir(filename), 0755)
 
-	data, err := json.Marshal(Config{Auth: &newAuth})
+	data, err := json.Marshal(cfg)
 	if err != nil {
 		return err
 	}
+	return ioutil.WriteFile(filename, data, 0600)
+}
+
+// SaveAuthToConfigFile will save auth for a profile to the configured credential store.
+// When the tokens go to the keyring, any copy left in the config file is removed.
+func SaveAuthToConfigFile(newAuth auth.Auth, profile string, devFlag bool) error {
+	_, err := SaveCredentials(newAuth, profile, devFlag)
+	return err
+}
+
+// SaveCredentials saves auth for a profile to the configured credential store and returns the store used.
+// If the keyring is picked automatically but cannot be written, the config file is used instead.
+func SaveCredentials(newAuth auth.Auth, profile string, devFlag bool) (CredentialStore, error) {
+	file := NewFileStore(profile, devFlag)
+	cfg, err := readConfig(file.Filename)
+	if err != nil {
+		return nil, err
+	}
+
+	store, err := CredentialStoreFor(cfg, profile, devFlag)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, ok := store.(*KeyringStore); ok {
+		err = store.Save(newAuth)
+		if err == nil {
+			// keep the profile in the config file so it is listed, but without the tokens
+			return store, file.Remove()
+		}
+		if cfg.CredentialStore == StoreKeyring {
+			return nil, err
+		}
+	}
+
+	return file, file.Save(newAuth)
+}
+
+// ReadJWKS returns the cached signing keys of the Auth0 tenant at authURL
+func ReadJWKS(authURL string) ([]byte, error) {
+	return ReadConfigFile(JWKSFile(authURL))
+}
+
+// SaveJWKS caches the signing keys of the Auth0 tenant at authURL
+func SaveJWKS(data []byte, authURL string) error {
+	os.MkdirAll(Dir(), 0755)
+	return ioutil.WriteFile(JWKSFile(authURL), data, 0644)
+}
+
+// NewKeyCache returns a cache of the signing keys of the Auth0 tenant at
+// authURL, kept in the config directory
+func NewKeyCache(authURL string, fetch func() ([]byte, error)) *auth.KeyCache {
+	return &auth.KeyCache{
+		Fetch: fetch,
+		Load: func() ([]byte, time.Time, error) {
+			info, err := os.Stat(JWKSFile(authURL))
+			if err != nil {
+				return nil, time.Time{}, err
+			}
+			data, err := ReadJWKS(authURL)
+			return data, info.ModTime(), err
+		},
+		Save: func(data []byte) error {
+			return SaveJWKS(data, authURL)
+		},
+	}
+}
+
+// NewTokenManager returns a token manager for the given credentials that saves
+// refreshed tokens back to the config file
+func NewTokenManager(tokens *auth.Auth, profile string, devFlag bool, refresh func(refreshToken string) (*auth.Auth, error)) *auth.Manager {
+	return &auth.Manager{
+		Tokens:  tokens,
+		Refresh: refresh,
+		Save: func(fresh auth.Auth) error {
+			return SaveAuthToConfigFile(fresh, profile, devFlag)
+		},
+	}
+}
+
+// RemoveAuthFromConfigFile attempts to remove a profile's credentials from the
+// config file and the OS keyring. The config file is deleted once it is empty.
+// The config file is updated even when the keyring fails, in which case a
+// *KeyringError is returned.
+func RemoveAuthFromConfigFile(profile string, devFlag bool) error {
+	return removeAuth(availableKeyring(profile, devFlag), NewFileStore(profile, devFlag))
+}
+
+// removeAuth removes credentials from the keyring, which is nil when there is
+// none, and from the config file
+func removeAuth(keyring *KeyringStore, file *FileStore) error {
+	keyringErr := removeFromKeyring(keyring)
+	if err := file.Remove(); err != nil {
+		return err
+	}
+	return keyringErr
+}
 
-	if err = ioutil.WriteFile(File(devFlag), data, 0600); err != nil {
+// RemoveProfile removes a profile's credentials and settings. As with
+// RemoveAuthFromConfigFile, a keyring failure is returned as a *KeyringError
+// once the config file is updated.
+func RemoveProfile(profile string, devFlag bool) error {
+	keyringErr := removeFromKeyring(availableKeyring(profile, devFlag))
+	err := UpdateConfig(devFlag, func(cfg *Config) error {
+		if cfg.GetProfile(profile) == nil {
+			return &ProfileNotFoundError{Name: profile}
+		}
+		cfg.RemoveProfile(profile)
+		return nil
+	})
+	if err != nil {
 		return err
 	}
+	return keyringErr
+}
+
+// KeyringError is returned when the config file was read or updated but the
+// keyring could not be. The credentials in the config file are still handled.
+type KeyringError struct {
+	Err error
+}
+
+func (e *KeyringError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *KeyringError) Unwrap() error {
+	return e.Err
+}
+
+// availableKeyring returns the keyring entry for a profile, or nil when there is no keyring
+func availableKeyring(profile string, devFlag bool) *KeyringStore {
+	if keyring := NewKeyringStore(profile, devFlag); keyring.Available() {
+		return keyring
+	}
 	return nil
 }
 
-// RemoveAuthFromConfigFile attempts to remove the auth file from the filesystem
-func RemoveAuthFromConfigFile(devFlag bool) error {
-	err := os.Remove(File(devFlag))
-	if err != nil && !os.IsNotExist(err) {
-		return err
+func removeFromKeyring(keyring *KeyringStore) error {
+	if keyring == nil {
+		return nil
+	}
+	if err := keyring.Remove(); err != nil {
+		return &KeyringError{Err: err}
 	}
 	return nil
 }
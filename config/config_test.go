@@ -1,6 +1,7 @@
 package config
 
 import (
+	"path/filepath"
 	"reflect"
 	"testing"
 )
@@ -33,6 +34,24 @@ func TestParseConfigFile_ValidConfig(t *testing.T) {
 	eq(t, auth.RefreshToken, "fakerefreshtoken")
 }
 
+func TestParseConfigFile_Network(t *testing.T) {
+	defer StubConfig(`{"network":{"proxy":"http://proxy.corp:3128","ca_bundle":"/etc/corp-ca.pem","insecure_skip_verify":true}}`)()
+	config, err := ParseConfigFile("somefile")
+	eq(t, err, nil)
+	eq(t, config.Network, &Network{
+		Proxy:              "http://proxy.corp:3128",
+		CABundle:           "/etc/corp-ca.pem",
+		InsecureSkipVerify: true,
+	})
+}
+
+func TestJWKSFile_PerTenant(t *testing.T) {
+	prod := JWKSFile("https://auth.featurepeek.com")
+	eq(t, JWKSFile("https://auth.featurepeek.com"), prod)
+	eq(t, JWKSFile("https://auth.example.com") == prod, false)
+	eq(t, filepath.Dir(prod), Dir())
+}
+
 // Helper functions
 func StubConfig(content string) func() {
 	orig := ReadConfigFile
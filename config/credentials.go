@@ -0,0 +1,239 @@
+package config
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os/exec"
+	"peek/auth"
+	"peek/run"
+	"runtime"
+	"strings"
+)
+
+// Values of the credential_store setting in the config file
+const (
+	// StoreAuto uses the OS keyring when it is available and the config file otherwise
+	StoreAuto    = "auto"
+	StoreKeyring = "keyring"
+	StoreFile    = "file"
+)
+
+// CredentialStore saves and loads the CLI's tokens
+type CredentialStore interface {
+	// Name describes where the credentials are kept
+	Name() string
+	// Load returns the stored credentials, or nil if there are none
+	Load() (*auth.Auth, error)
+	Save(auth.Auth) error
+	Remove() error
+}
+
+// CredentialStoreFor returns the credential store chosen by the config's credential_store setting
+func CredentialStoreFor(cfg *Config, profile string, devFlag bool) (CredentialStore, error) {
+	keyring := NewKeyringStore(profile, devFlag)
+	switch cfg.CredentialStore {
+	case "", StoreAuto:
+		if keyring.Available() {
+			return keyring, nil
+		}
+		return NewFileStore(profile, devFlag), nil
+	case StoreKeyring:
+		if !keyring.Available() {
+			return nil, fmt.Errorf("credential_store is %q but no supported keyring was found (%s)", StoreKeyring, keyring.tool())
+		}
+		return keyring, nil
+	case StoreFile:
+		return NewFileStore(profile, devFlag), nil
+	}
+	return nil, fmt.Errorf("unknown credential_store %q: expected %s, %s or %s", cfg.CredentialStore, StoreAuto, StoreKeyring, StoreFile)
+}
+
+// FileStore keeps a profile's credentials in the config file, readable only by the user
+type FileStore struct {
+	Filename string
+	Profile  string
+}
+
+// NewFileStore returns the store for a profile in the config file of the given environment
+func NewFileStore(profile string, devFlag bool) *FileStore {
+	return &FileStore{Filename: File(devFlag), Profile: profile}
+}
+
+// Name describes the file store
+func (s *FileStore) Name() string {
+	return s.Filename
+}
+
+// Load returns the credentials saved in the config file
+func (s *FileStore) Load() (*auth.Auth, error) {
+	cfg, err := readConfig(s.Filename)
+	if err != nil {
+		return nil, err
+	}
+	if profile := cfg.GetProfile(s.Profile); profile != nil {
+		return profile.Auth, nil
+	}
+	return nil, nil
+}
+
+// Save writes the credentials to the config file, keeping its other settings
+func (s *FileStore) Save(newAuth auth.Auth) error {
+	return s.update(&newAuth)
+}
+
+// Remove deletes the credentials from the config file, keeping the profile and its other settings
+func (s *FileStore) Remove() error {
+	return s.update(nil)
+}
+
+func (s *FileStore) update(newAuth *auth.Auth) error {
+	cfg, err := readConfig(s.Filename)
+	if err != nil {
+		return err
+	}
+	profile := cfg.GetProfile(s.Profile)
+	if profile == nil {
+		if newAuth == nil {
+			return nil
+		}
+		profile = &Profile{}
+	}
+	profile.Auth = newAuth
+	cfg.SetProfile(s.Profile, profile)
+	return writeConfig(s.Filename, cfg)
+}
+
+// KeyringStore keeps credentials in the OS keyring: the Secret Service through
+// secret-tool on Linux and the login keychain through security on macOS
+type KeyringStore struct {
+	Service string
+	Account string
+	// GOOS picks the keyring tool; it defaults to runtime.GOOS
+	GOOS string
+}
+
+// NewKeyringStore returns the keyring entry for a profile in the given environment
+func NewKeyringStore(profile string, devFlag bool) *KeyringStore {
+	service := "peek"
+	if devFlag {
+		service = "peek-dev"
+	}
+	return &KeyringStore{Service: service, Account: profile}
+}
+
+// Name describes the keyring store
+func (s *KeyringStore) Name() string {
+	return "system keyring"
+}
+
+func (s *KeyringStore) goos() string {
+	if s.GOOS != "" {
+		return s.GOOS
+	}
+	return runtime.GOOS
+}
+
+func (s *KeyringStore) tool() string {
+	switch s.goos() {
+	case "linux", "freebsd", "openbsd":
+		return "secret-tool"
+	case "darwin":
+		return "security"
+	}
+	return ""
+}
+
+// Available reports whether the keyring tool for this OS is installed
+func (s *KeyringStore) Available() bool {
+	tool := s.tool()
+	if tool == "" {
+		return false
+	}
+	_, err := exec.LookPath(tool)
+	return err == nil
+}
+
+// Load returns the credentials saved in the keyring
+func (s *KeyringStore) Load() (*auth.Auth, error) {
+	var cmd *exec.Cmd
+	if s.tool() == "security" {
+		cmd = exec.Command("security", "find-generic-password", "-s", s.Service, "-a", s.Account, "-w")
+	} else {
+		cmd = exec.Command("secret-tool", "lookup", "service", s.Service, "account", s.Account)
+	}
+
+	output, err := run.PrepareCmd(cmd).Output()
+	if err != nil {
+		if keyringNotFound(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("could not read credentials from the keyring: %v", err)
+	}
+
+	data := strings.TrimSpace(string(output))
+	if data == "" {
+		return nil, nil
+	}
+	var stored auth.Auth
+	if err = json.Unmarshal([]byte(data), &stored); err != nil {
+		return nil, fmt.Errorf("could not read credentials from the keyring: %v", err)
+	}
+	return &stored, nil
+}
+
+// Save writes the credentials to the keyring, replacing any previous entry
+func (s *KeyringStore) Save(newAuth auth.Auth) error {
+	data, err := json.Marshal(newAuth)
+	if err != nil {
+		return err
+	}
+
+	label := fmt.Sprintf("FeaturePeek CLI (%s)", s.Account)
+	var cmd *exec.Cmd
+	if s.tool() == "security" {
+		// the command is read from stdin so the password never appears in the process list
+		cmd = exec.Command("security", "-i")
+		cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %s -l %s -X %s\n",
+			securityQuote(s.Service), securityQuote(s.Account), securityQuote(label), hex.EncodeToString(data)))
+	} else {
+		cmd = exec.Command("secret-tool", "store", "--label", label, "service", s.Service, "account", s.Account)
+		cmd.Stdin = strings.NewReader(string(data))
+	}
+
+	if err = run.PrepareCmd(cmd).Run(); err != nil {
+		return fmt.Errorf("could not save credentials to the keyring: %v", err)
+	}
+	return nil
+}
+
+// Remove deletes the credentials from the keyring
+func (s *KeyringStore) Remove() error {
+	var cmd *exec.Cmd
+	if s.tool() == "security" {
+		cmd = exec.Command("security", "delete-generic-password", "-s", s.Service, "-a", s.Account)
+	} else {
+		cmd = exec.Command("secret-tool", "clear", "service", s.Service, "account", s.Account)
+	}
+
+	if err := run.PrepareCmd(cmd).Run(); err != nil && !keyringNotFound(err) {
+		return fmt.Errorf("could not remove credentials from the keyring: %v", err)
+	}
+	return nil
+}
+
+// securityQuote quotes an argument for a command line read by security -i
+func securityQuote(arg string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+}
+
+// keyringNotFound reports whether a keyring command failed because the entry does not exist.
+// secret-tool exits without output; security reports that the item could not be found.
+func keyringNotFound(err error) bool {
+	cmdErr, ok := err.(*run.CmdError)
+	if !ok {
+		return false
+	}
+	stderr := strings.TrimSpace(cmdErr.Stderr.String())
+	return stderr == "" || strings.Contains(stderr, "could not be found")
+}
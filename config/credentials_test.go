@@ -0,0 +1,159 @@
+package config
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"peek/auth"
+	"peek/test"
+	"reflect"
+	"testing"
+)
+
+func TestKeyringStore_SecretTool(t *testing.T) {
+	cs, teardown := test.InitCmdStubber()
+	defer teardown()
+
+	store := &KeyringStore{Service: "peek", Account: "default", GOOS: "linux"}
+
+	cs.Stub("")
+	err := store.Save(auth.Auth{AccessToken: "access", RefreshToken: "refresh"})
+	eq(t, err, nil)
+	eq(t, cs.Calls[0].Args, []string{"secret-tool", "store", "--label", "FeaturePeek CLI (default)", "service", "peek", "account", "default"})
+	stdin, _ := ioutil.ReadAll(cs.Calls[0].Stdin)
+	eq(t, string(stdin), `{"access_token":"access","refresh_token":"refresh","id_token":"","token_type":"","expires_in":0}`)
+
+	cs.Stub(`{"access_token":"access","refresh_token":"refresh"}` + "\n")
+	loaded, err := store.Load()
+	eq(t, err, nil)
+	eq(t, loaded, &auth.Auth{AccessToken: "access", RefreshToken: "refresh"})
+	eq(t, cs.Calls[1].Args, []string{"secret-tool", "lookup", "service", "peek", "account", "default"})
+
+	cs.Stub("")
+	eq(t, store.Remove(), nil)
+	eq(t, cs.Calls[2].Args, []string{"secret-tool", "clear", "service", "peek", "account", "default"})
+}
+
+func TestKeyringStore_Security(t *testing.T) {
+	cs, teardown := test.InitCmdStubber()
+	defer teardown()
+
+	store := &KeyringStore{Service: "peek-dev", Account: "default", GOOS: "darwin"}
+
+	cs.Stub("")
+	err := store.Save(auth.Auth{AccessToken: "access"})
+	eq(t, err, nil)
+	data, _ := json.Marshal(auth.Auth{AccessToken: "access"})
+	eq(t, cs.Calls[0].Args, []string{"security", "-i"})
+	stdin, _ := ioutil.ReadAll(cs.Calls[0].Stdin)
+	eq(t, string(stdin), `add-generic-password -U -s "peek-dev" -a "default" -l "FeaturePeek CLI (default)" -X `+
+		hex.EncodeToString(data)+"\n")
+
+	cs.Stub(`{"access_token":"access"}`)
+	loaded, err := store.Load()
+	eq(t, err, nil)
+	eq(t, loaded.AccessToken, "access")
+	eq(t, cs.Calls[1].Args, []string{"security", "find-generic-password", "-s", "peek-dev", "-a", "default", "-w"})
+}
+
+func TestKeyringStore_NotFound(t *testing.T) {
+	cs, teardown := test.InitCmdStubber()
+	defer teardown()
+
+	linux := &KeyringStore{Service: "peek", Account: "default", GOOS: "linux"}
+	cs.StubError("")
+	loaded, err := linux.Load()
+	eq(t, err, nil)
+	eq(t, loaded, (*auth.Auth)(nil))
+
+	mac := &KeyringStore{Service: "peek", Account: "default", GOOS: "darwin"}
+	cs.StubError("security: The specified item could not be found in the keychain.")
+	eq(t, mac.Remove(), nil)
+
+	cs.StubError("Cannot autolaunch D-Bus without X11 $DISPLAY")
+	if _, err = linux.Load(); err == nil {
+		t.Error("expected a keyring error")
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	store := &FileStore{Filename: filepath.Join(t.TempDir(), "config.json"), Profile: DefaultProfile}
+
+	loaded, err := store.Load()
+	eq(t, err, nil)
+	eq(t, loaded, (*auth.Auth)(nil))
+
+	if err = ioutil.WriteFile(store.Filename, []byte(`{"credential_store":"file"}`), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	eq(t, store.Save(auth.Auth{AccessToken: "access"}), nil)
+	loaded, err = store.Load()
+	eq(t, err, nil)
+	eq(t, loaded.AccessToken, "access")
+
+	eq(t, store.Remove(), nil)
+	cfg, err := ParseConfigFile(store.Filename)
+	eq(t, err, nil)
+	eq(t, cfg, &Config{CredentialStore: StoreFile})
+}
+
+func TestFileStore_Profile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.json")
+	if err := ioutil.WriteFile(filename, []byte(validConfig), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	work := &FileStore{Filename: filename, Profile: "work"}
+	eq(t, work.Save(auth.Auth{AccessToken: "work-token"}), nil)
+
+	cfg, err := ParseConfigFile(filename)
+	eq(t, err, nil)
+	eq(t, cfg.Auth.AccessToken, "fakeaccesstoken")
+	eq(t, cfg.Profiles["work"].Auth.AccessToken, "work-token")
+
+	defaultStore := &FileStore{Filename: filename, Profile: DefaultProfile}
+	eq(t, defaultStore.Remove(), nil)
+	eq(t, work.Remove(), nil)
+
+	cfg, err = ParseConfigFile(filename)
+	eq(t, err, nil)
+	eq(t, cfg, &Config{Profiles: map[string]*Profile{"work": {}}})
+}
+
+func TestFileStore_RemoveMissingProfile(t *testing.T) {
+	filename := filepath.Join(t.TempDir(), "config.json")
+	eq(t, (&FileStore{Filename: filename, Profile: "work"}).Remove(), nil)
+
+	_, err := ParseConfigFile(filename)
+	eq(t, os.IsNotExist(err), true)
+}
+
+func TestRemoveAuth_KeyringFailure(t *testing.T) {
+	cs, teardown := test.InitCmdStubber()
+	defer teardown()
+
+	store := &FileStore{Filename: filepath.Join(t.TempDir(), "config.json"), Profile: DefaultProfile}
+	eq(t, store.Save(auth.Auth{AccessToken: "access"}), nil)
+
+	cs.StubError("Cannot autolaunch D-Bus without X11 $DISPLAY")
+	err := removeAuth(&KeyringStore{Service: "peek", Account: DefaultProfile, GOOS: "linux"}, store)
+	var keyringErr *KeyringError
+	eq(t, errors.As(err, &keyringErr), true)
+
+	loaded, err := store.Load()
+	eq(t, err, nil)
+	eq(t, loaded, (*auth.Auth)(nil))
+}
+
+func TestCredentialStoreFor(t *testing.T) {
+	store, err := CredentialStoreFor(&Config{CredentialStore: StoreFile}, DefaultProfile, false)
+	eq(t, err, nil)
+	eq(t, reflect.TypeOf(store), reflect.TypeOf(&FileStore{}))
+
+	_, err = CredentialStoreFor(&Config{CredentialStore: "vault"}, DefaultProfile, false)
+	eq(t, err.Error(), `unknown credential_store "vault": expected auto, keyring or file`)
+}
@@ -0,0 +1,80 @@
+package config
+
+import (
+	"os"
+	"strings"
+)
+
+// Environment variables that override the endpoints in the config file
+const (
+	APIURLEnv   = "PEEK_API_URL"
+	AuthURLEnv  = "PEEK_AUTH_URL"
+	ClientIDEnv = "PEEK_CLIENT_ID"
+)
+
+// Environment holds the FeaturePeek API and Auth0 endpoints the CLI talks to
+type Environment struct {
+	// Name is prod, or dev when the --dev flag is set
+	Name string
+	// APIURL is the base URL of the FeaturePeek API, without a trailing slash
+	APIURL string
+	// AuthURL is the base URL of the Auth0 tenant, without a trailing slash
+	AuthURL  string
+	ClientID string
+	// Audience is the API identifier that access tokens are requested for
+	Audience string
+}
+
+// ProdEnvironment is the public FeaturePeek service
+var ProdEnvironment = Environment{
+	Name:     "prod",
+	APIURL:   "https://api.featurepeek.com",
+	AuthURL:  "https://login.featurepeek.com",
+	ClientID: "oB2RkLUylDTrsSxVa6qdLR3DQMbdh9IR",
+	Audience: "http://api.featurepeek.com/api/v1/",
+}
+
+// DevEnvironment is the FeaturePeek development service used with --dev
+var DevEnvironment = Environment{
+	Name:     "dev",
+	APIURL:   "https://api.dev.featurepeek.com",
+	AuthURL:  "https://featurepeek-dev.auth0.com",
+	ClientID: "XnNVx0nzQSJdY6ksPGTnnciuGOM8kXsT",
+	Audience: "http://api.dev.featurepeek.com/api/v1/",
+}
+
+// Issuer returns the iss claim of tokens issued by the Auth0 tenant
+func (e Environment) Issuer() string {
+	return e.AuthURL + "/"
+}
+
+// ResolveEnvironment returns the endpoints for a profile, which may be nil.
+// PEEK_API_URL, PEEK_AUTH_URL and PEEK_CLIENT_ID take precedence over the
+// profile's settings, which take precedence over the prod or dev defaults.
+func ResolveEnvironment(profile *Profile, devFlag bool) Environment {
+	env := ProdEnvironment
+	if devFlag {
+		env = DevEnvironment
+	}
+
+	if profile != nil {
+		override(&env.APIURL, profile.APIURL)
+		override(&env.AuthURL, profile.AuthURL)
+		override(&env.ClientID, profile.ClientID)
+		override(&env.Audience, profile.Audience)
+	}
+
+	override(&env.APIURL, os.Getenv(APIURLEnv))
+	override(&env.AuthURL, os.Getenv(AuthURLEnv))
+	override(&env.ClientID, os.Getenv(ClientIDEnv))
+
+	env.APIURL = strings.TrimSuffix(env.APIURL, "/")
+	env.AuthURL = strings.TrimSuffix(env.AuthURL, "/")
+	return env
+}
+
+func override(field *string, value string) {
+	if value != "" {
+		*field = value
+	}
+}
@@ -0,0 +1,61 @@
+package config
+
+import (
+	"os"
+	"testing"
+)
+
+func TestResolveEnvironment_Defaults(t *testing.T) {
+	defer stubEnv(map[string]string{APIURLEnv: "", AuthURLEnv: "", ClientIDEnv: ""})()
+
+	eq(t, ResolveEnvironment(nil, false), ProdEnvironment)
+	eq(t, ResolveEnvironment(&Profile{}, true), DevEnvironment)
+}
+
+func TestResolveEnvironment_Overrides(t *testing.T) {
+	defer stubEnv(map[string]string{APIURLEnv: "", AuthURLEnv: "https://auth.staging.test/", ClientIDEnv: ""})()
+
+	profile := &Profile{APIURL: "https://api.staging.test/", ClientID: "profile-client", Audience: "https://api.staging.test/api/v1/"}
+	env := ResolveEnvironment(profile, false)
+	eq(t, env, Environment{
+		Name:     "prod",
+		APIURL:   "https://api.staging.test",
+		AuthURL:  "https://auth.staging.test",
+		ClientID: "profile-client",
+		Audience: "https://api.staging.test/api/v1/",
+	})
+	eq(t, env.Issuer(), "https://auth.staging.test/")
+
+	os.Setenv(APIURLEnv, "http://localhost:8080")
+	os.Setenv(ClientIDEnv, "env-client")
+	env = ResolveEnvironment(profile, false)
+	eq(t, env.APIURL, "http://localhost:8080")
+	eq(t, env.ClientID, "env-client")
+}
+
+// stubEnv sets environment variables, unsetting empty ones, and returns a func to restore them
+func stubEnv(vars map[string]string) func() {
+	type saved struct {
+		value string
+		set   bool
+	}
+	orig := make(map[string]saved)
+	for name, value := range vars {
+		v, ok := os.LookupEnv(name)
+		orig[name] = saved{v, ok}
+		if value == "" {
+			os.Unsetenv(name)
+		} else {
+			os.Setenv(name, value)
+		}
+	}
+	return func() {
+		for name, s := range orig {
+			if s.set {
+				os.Setenv(name, s.value)
+			} else {
+				os.Unsetenv(name)
+			}
+		}
+	}
+}
@@ -0,0 +1,143 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"peek/auth"
+	"sort"
+)
+
+// DefaultProfile is used when no profile is selected. Its settings are kept at
+// the top level of the config file, where earlier versions stored credentials.
+const DefaultProfile = "default"
+
+// ProfileEnv is the environment variable that selects a profile
+const ProfileEnv = "PEEK_PROFILE"
+
+// TokenEnv is the environment variable holding the access token used by `peek deploy --ci`
+const TokenEnv = "PEEK_TOKEN"
+
+// Profile holds the credentials and endpoints of one FeaturePeek account.
+// Empty endpoints use the defaults of the prod or dev environment.
+type Profile struct {
+	Auth     *auth.Auth `json:"auth,omitempty"`
+	APIURL   string     `json:"api_url,omitempty"`
+	AuthURL  string     `json:"auth_url,omitempty"`
+	ClientID string     `json:"client_id,omitempty"`
+	Audience string     `json:"audience,omitempty"`
+}
+
+// ProfileName returns the selected profile: the --profile flag, then
+// PEEK_PROFILE, then the profile chosen with `peek profile use`
+func ProfileName(flag string, cfg *Config) string {
+	if flag != "" {
+		return flag
+	}
+	if env := os.Getenv(ProfileEnv); env != "" {
+		return env
+	}
+	if cfg != nil && cfg.CurrentProfile != "" {
+		return cfg.CurrentProfile
+	}
+	return DefaultProfile
+}
+
+// GetProfile returns a copy of the named profile, or nil if it does not exist.
+// The default profile always exists.
+func (c *Config) GetProfile(name string) *Profile {
+	if name == DefaultProfile {
+		profile := c.Profile
+		return &profile
+	}
+	if p, ok := c.Profiles[name]; ok {
+		profile := *p
+		return &profile
+	}
+	return nil
+}
+
+// SetProfile creates or replaces the named profile
+func (c *Config) SetProfile(name string, p *Profile) {
+	if name == DefaultProfile {
+		c.Profile = *p
+		return
+	}
+	if c.Profiles == nil {
+		c.Profiles = make(map[string]*Profile)
+	}
+	c.Profiles[name] = p
+}
+
+// RemoveProfile deletes the named profile. Removing the default profile clears its settings.
+func (c *Config) RemoveProfile(name string) {
+	if name == DefaultProfile {
+		c.Profile = Profile{}
+	} else {
+		delete(c.Profiles, name)
+		if len(c.Profiles) == 0 {
+			c.Profiles = nil
+		}
+	}
+	if c.CurrentProfile == name {
+		c.CurrentProfile = ""
+	}
+}
+
+// ProfileNames returns the default profile followed by the other profiles sorted by name
+func (c *Config) ProfileNames() []string {
+	var names []string
+	for name := range c.Profiles {
+		if name != DefaultProfile {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return append([]string{DefaultProfile}, names...)
+}
+
+// LoadProfile loads the named profile. Credentials that are not in the config
+// file, which may not exist, are loaded from the OS keyring. When the keyring
+// cannot be read the profile is returned without credentials along with a
+// *KeyringError.
+func LoadProfile(name string, devFlag bool) (*Profile, error) {
+	cfg, err := readConfig(File(devFlag))
+	if err != nil {
+		return nil, err
+	}
+
+	profile := cfg.GetProfile(name)
+	if profile == nil {
+		return nil, &ProfileNotFoundError{Name: name}
+	}
+
+	if profile.Auth == nil && cfg.CredentialStore != StoreFile {
+		if keyring := NewKeyringStore(name, devFlag); keyring.Available() {
+			if profile.Auth, err = keyring.Load(); err != nil {
+				return profile, &KeyringError{Err: err}
+			}
+		}
+	}
+	return profile, nil
+}
+
+// UpdateConfig reads the config file, applies update and writes it back
+func UpdateConfig(devFlag bool, update func(*Config) error) error {
+	filename := File(devFlag)
+	cfg, err := readConfig(filename)
+	if err != nil {
+		return err
+	}
+	if err = update(cfg); err != nil {
+		return err
+	}
+	return writeConfig(filename, cfg)
+}
+
+// ProfileNotFoundError is returned when a profile that was asked for does not exist
+type ProfileNotFoundError struct {
+	Name string
+}
+
+func (e *ProfileNotFoundError) Error() string {
+	return fmt.Sprintf("profile %q not found; run `peek login --profile %s` to create it", e.Name, e.Name)
+}
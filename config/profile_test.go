@@ -0,0 +1,48 @@
+package config
+
+import (
+	"os"
+	"peek/auth"
+	"testing"
+)
+
+func TestParseConfigFile_LegacyConfigIsDefaultProfile(t *testing.T) {
+	defer StubConfig(validConfig)()
+	cfg, err := ParseConfigFile("somefile")
+	eq(t, err, nil)
+
+	profile := cfg.GetProfile(DefaultProfile)
+	eq(t, profile.Auth.AccessToken, "fakeaccesstoken")
+	eq(t, cfg.GetProfile("work"), (*Profile)(nil))
+	eq(t, cfg.ProfileNames(), []string{DefaultProfile})
+}
+
+func TestConfig_Profiles(t *testing.T) {
+	cfg := &Config{}
+	cfg.SetProfile("work", &Profile{Auth: &auth.Auth{AccessToken: "work"}, APIURL: "https://api.example.com"})
+	cfg.SetProfile("personal", &Profile{})
+	cfg.SetProfile(DefaultProfile, &Profile{APIURL: "https://default.example.com"})
+	cfg.CurrentProfile = "work"
+
+	eq(t, cfg.ProfileNames(), []string{DefaultProfile, "personal", "work"})
+	eq(t, cfg.APIURL, "https://default.example.com")
+	eq(t, cfg.GetProfile("work").APIURL, "https://api.example.com")
+
+	cfg.RemoveProfile("work")
+	eq(t, cfg.CurrentProfile, "")
+	eq(t, cfg.ProfileNames(), []string{DefaultProfile, "personal"})
+
+	cfg.RemoveProfile("personal")
+	eq(t, cfg.Profiles, map[string]*Profile(nil))
+}
+
+func TestProfileName(t *testing.T) {
+	defer stubEnv(map[string]string{ProfileEnv: ""})()
+
+	eq(t, ProfileName("", nil), DefaultProfile)
+	eq(t, ProfileName("", &Config{CurrentProfile: "work"}), "work")
+
+	os.Setenv(ProfileEnv, "personal")
+	eq(t, ProfileName("", &Config{CurrentProfile: "work"}), "personal")
+	eq(t, ProfileName("ci", &Config{CurrentProfile: "work"}), "ci")
+}
@@ -13,6 +13,9 @@ import (
 	"peek/run"
 )
 
+// ErrNotOnBranch is returned by CurrentBranch when HEAD is detached
+var ErrNotOnBranch = errors.New("git: not on any branch")
+
 // Ref represents a git commit reference
 type Ref struct {
 	Hash string
@@ -64,7 +67,7 @@ func CurrentBranch() (string, error) {
 	if errors.As(err, &cmdErr) {
 		if cmdErr.Stderr.Len() == 0 {
 			// Detached head
-			return "", errors.New("git: not on any branch")
+			return "", ErrNotOnBranch
 		}
 	}
 
@@ -92,6 +95,23 @@ func CheckForFileOnRemoteBranch(branch string, file string) error {
 	return run.PrepareCmd(checkCmd).Run()
 }
 
+// CheckForFileInCommit looks for a file's existence in the tree of a commit
+func CheckForFileInCommit(sha string, file string) error {
+	checkCmd := GitCommand("cat-file", "-e", sha+":"+file)
+	return run.PrepareCmd(checkCmd).Run()
+}
+
+// RemoteTagExists asks a remote whether it has the given tag, rather than
+// trusting the local tags, which may never have been pushed
+func RemoteTagExists(remote string, tag string) (bool, error) {
+	lsCmd := GitCommand("ls-remote", "--tags", remote, "refs/tags/"+tag)
+	output, err := run.PrepareCmd(lsCmd).Output()
+	if err != nil {
+		return false, err
+	}
+	return firstLine(output) != "", nil
+}
+
 // ShaForRemoteBranch return the commit hash of the given branch on the origin remote
 func ShaForRemoteBranch(branch string) (string, error) {
 	originRef := fmt.Sprintf("refs/remotes/origin/%s", branch)
@@ -100,6 +120,54 @@ func ShaForRemoteBranch(branch string) (string, error) {
 	return firstLine(output), err
 }
 
+// ResolveCommit returns the commit hash a revision, such as a tag, branch or
+// abbreviated hash, points to
+func ResolveCommit(rev string) (string, error) {
+	revCmd := GitCommand("rev-parse", "--verify", "--quiet", rev+"^{commit}")
+	output, err := run.PrepareCmd(revCmd).Output()
+	if err != nil {
+		return "", fmt.Errorf("git: unknown revision %s", rev)
+	}
+	return firstLine(output), nil
+}
+
+// IsAncestor reports whether the commit ancestor is in the history of rev,
+// which is true when rev can be fast-forwarded from it
+func IsAncestor(ancestor string, rev string) (bool, error) {
+	mergeBaseCmd := GitCommand("merge-base", "--is-ancestor", ancestor, rev)
+	err := run.PrepareCmd(mergeBaseCmd).Run()
+	if err == nil {
+		return true, nil
+	}
+
+	var cmdErr *run.CmdError
+	if errors.As(err, &cmdErr) && cmdErr.Stderr.Len() == 0 {
+		// exits 1 without output when it is not an ancestor
+		return false, nil
+	}
+	return false, err
+}
+
+// RemoteBranchesContaining lists the branches of a remote whose tip has the
+// given commit in its history, according to the remote tracking refs
+func RemoteBranchesContaining(remote string, sha string) ([]string, error) {
+	prefix := fmt.Sprintf("refs/remotes/%s/", remote)
+	refCmd := GitCommand("for-each-ref", "--contains", sha, "--format=%(refname)", prefix)
+	output, err := run.PrepareCmd(refCmd).Output()
+	if err != nil {
+		return nil, err
+	}
+
+	var branches []string
+	for _, ref := range outputLines(output) {
+		branch := strings.TrimPrefix(ref, prefix)
+		if branch != "" && branch != "HEAD" {
+			branches = append(branches, branch)
+		}
+	}
+	return branches, nil
+}
+
 func listRemotes() ([]string, error) {
 	remoteCmd := exec.Command("git", "remote", "-v")
 	output, err := run.PrepareCmd(remoteCmd).Output()
@@ -1,7 +1,9 @@
 package git
 
 import (
+	"errors"
 	"os/exec"
+	"strings"
 	"testing"
 
 	"peek/run"
@@ -48,7 +50,7 @@ func Test_CurrentBranch(t *testing.T) {
 
 	result, err := CurrentBranch()
 	if err != nil {
-		t.Errorf("got unexpected error: %w", err)
+		t.Errorf("got unexpected error: %v", err)
 	}
 	if len(cs.Calls) != 1 {
 		t.Errorf("expected 1 git call, saw %d", len(cs.Calls))
@@ -68,9 +70,8 @@ func Test_CurrentBranch_detached_head(t *testing.T) {
 	if err == nil {
 		t.Errorf("expected an error")
 	}
-	expectedError := "git: not on any branch"
-	if err.Error() != expectedError {
-		t.Errorf("got unexpected error: %s instead of %s", err.Error(), expectedError)
+	if !errors.Is(err, ErrNotOnBranch) {
+		t.Errorf("got unexpected error: %v instead of %v", err, ErrNotOnBranch)
 	}
 	if len(cs.Calls) != 1 {
 		t.Errorf("expected 1 git call, saw %d", len(cs.Calls))
@@ -96,3 +97,106 @@ func Test_CurrentBranch_unexpected_error(t *testing.T) {
 		t.Errorf("expected 1 git call, saw %d", len(cs.Calls))
 	}
 }
+
+func Test_ResolveCommit(t *testing.T) {
+	cs, teardown := test.InitCmdStubber()
+	defer teardown()
+
+	cs.Stub("0123456789abcdef\n")
+	sha, err := ResolveCommit("v1.4.0")
+	if err != nil {
+		t.Fatalf("got unexpected error: %v", err)
+	}
+	if sha != "0123456789abcdef" {
+		t.Errorf("unexpected sha: %s", sha)
+	}
+	if args := strings.Join(cs.Calls[0].Args, " "); args != "git rev-parse --verify --quiet v1.4.0^{commit}" {
+		t.Errorf("unexpected git call: %s", args)
+	}
+
+	cs.StubError("")
+	if _, err = ResolveCommit("nope"); err == nil || err.Error() != "git: unknown revision nope" {
+		t.Errorf("got unexpected error: %v", err)
+	}
+}
+
+func Test_RemoteBranchesContaining(t *testing.T) {
+	cs, teardown := test.InitCmdStubber()
+	defer teardown()
+
+	cs.Stub("refs/remotes/origin/HEAD\nrefs/remotes/origin/main\nrefs/remotes/origin/release/1.x\n")
+	branches, err := RemoteBranchesContaining("origin", "abc123")
+	if err != nil {
+		t.Fatalf("got unexpected error: %v", err)
+	}
+	if strings.Join(branches, ",") != "main,release/1.x" {
+		t.Errorf("unexpected branches: %v", branches)
+	}
+	if args := strings.Join(cs.Calls[0].Args, " "); args != "git for-each-ref --contains abc123 --format=%(refname) refs/remotes/origin/" {
+		t.Errorf("unexpected git call: %s", args)
+	}
+
+	cs.Stub("")
+	branches, err = RemoteBranchesContaining("origin", "abc123")
+	if err != nil || len(branches) != 0 {
+		t.Errorf("expected no branches, got %v, %v", branches, err)
+	}
+}
+
+func Test_RemoteTagExists(t *testing.T) {
+	cs, teardown := test.InitCmdStubber()
+	defer teardown()
+
+	cs.Stub("0123456789abcdef\trefs/tags/v1.4.0\n")
+	exists, err := RemoteTagExists("origin", "v1.4.0")
+	if err != nil || !exists {
+		t.Errorf("expected the tag to exist, got %v, %v", exists, err)
+	}
+	if args := strings.Join(cs.Calls[0].Args, " "); args != "git ls-remote --tags origin refs/tags/v1.4.0" {
+		t.Errorf("unexpected git call: %s", args)
+	}
+
+	cs.Stub("")
+	exists, err = RemoteTagExists("origin", "v1.5.0-local")
+	if err != nil || exists {
+		t.Errorf("expected the tag to be missing, got %v, %v", exists, err)
+	}
+}
+
+func Test_CheckForFileInCommit(t *testing.T) {
+	cs, teardown := test.InitCmdStubber()
+	defer teardown()
+
+	cs.StubError("fatal: path 'peek.yml' does not exist in 'abc123'")
+	if err := CheckForFileInCommit("abc123", "peek.yml"); err == nil {
+		t.Error("expected an error")
+	}
+	if args := strings.Join(cs.Calls[0].Args, " "); args != "git cat-file -e abc123:peek.yml" {
+		t.Errorf("unexpected git call: %s", args)
+	}
+}
+
+func Test_IsAncestor(t *testing.T) {
+	cs, teardown := test.InitCmdStubber()
+	defer teardown()
+
+	cs.Stub("")
+	ok, err := IsAncestor("abc123", "def456")
+	if err != nil || !ok {
+		t.Errorf("expected an ancestor, got %v, %v", ok, err)
+	}
+	if args := strings.Join(cs.Calls[0].Args, " "); args != "git merge-base --is-ancestor abc123 def456" {
+		t.Errorf("unexpected git call: %s", args)
+	}
+
+	cs.StubError("")
+	ok, err = IsAncestor("abc123", "def456")
+	if err != nil || ok {
+		t.Errorf("expected no ancestor, got %v, %v", ok, err)
+	}
+
+	cs.StubError("fatal: Not a valid commit name abc123")
+	if _, err = IsAncestor("abc123", "def456"); err == nil {
+		t.Error("expected an error")
+	}
+}
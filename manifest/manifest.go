@@ -0,0 +1,129 @@
+// Package manifest describes the contents of a build directory file by file
+package manifest
+
+import (
+	"bufio"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Entry describes a single regular file in a build directory
+type Entry struct {
+	Path string      `json:"path"`
+	Size int64       `json:"size"`
+	Mode os.FileMode `json:"mode"`
+	Hash string      `json:"sha256"`
+}
+
+// String formats the entry as a single manifest line:
+//
+//	<sha256> <size> <mode> <path>
+//
+// The path is quoted if it contains characters that would make the line ambiguous.
+func (e Entry) String() string {
+	path := e.Path
+	if strings.HasPrefix(path, `"`) || strconv.Quote(path) != `"`+path+`"` {
+		path = strconv.Quote(path)
+	}
+	return fmt.Sprintf("%s %d %04o %s", e.Hash, e.Size, e.Mode.Perm(), path)
+}
+
+// Manifest lists every regular file in a build directory, sorted by path
+type Manifest []Entry
+
+// Build walks dir and hashes each regular file, streaming its contents rather
+// than reading it into memory. Paths are relative to dir and use forward slashes.
+func Build(dir string) (Manifest, error) {
+	var m Manifest
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		hash, err := hashFile(path)
+		if err != nil {
+			return err
+		}
+
+		m = append(m, Entry{
+			Path: filepath.ToSlash(name),
+			Size: info.Size(),
+			Mode: info.Mode().Perm(),
+			Hash: hash,
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(m, func(i, j int) bool { return m[i].Path < m[j].Path })
+	return m, nil
+}
+
+// WriteTo writes the manifest one entry per line, in path order
+func (m Manifest) WriteTo(w io.Writer) (int64, error) {
+	bw := bufio.NewWriter(w)
+	var n int64
+	for _, e := range m {
+		written, err := fmt.Fprintln(bw, e.String())
+		n += int64(written)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, bw.Flush()
+}
+
+// String returns the manifest in the same format as WriteTo
+func (m Manifest) String() string {
+	var sb strings.Builder
+	m.WriteTo(&sb)
+	return sb.String()
+}
+
+// Checksum is the SHA-256 of the serialized manifest. Because every line
+// carries the file's path, size and mode, renaming or splitting files changes
+// the checksum even when the concatenated contents do not.
+func (m Manifest) Checksum() string {
+	h := sha256.New()
+	m.WriteTo(h)
+	return fmt.Sprintf("%x", h.Sum(nil))
+}
+
+// ByHash returns the entries keyed by content hash; files with identical
+// contents share a key
+func (m Manifest) ByHash() map[string]Entry {
+	entries := make(map[string]Entry, len(m))
+	for _, e := range m {
+		entries[e.Hash] = e
+	}
+	return entries
+}
+
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err = io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%x", h.Sum(nil)), nil
+}
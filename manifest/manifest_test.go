@@ -0,0 +1,95 @@
+package manifest
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBuild(t *testing.T) {
+	dir := makeDir(t, map[string]string{
+		"index.html":     "<html></html>",
+		"static/app.js":  "console.log('hi')",
+		"static/copy.js": "console.log('hi')",
+	})
+	defer os.RemoveAll(dir)
+
+	m, err := Build(dir)
+	eq(t, err, nil)
+
+	var paths []string
+	for _, e := range m {
+		paths = append(paths, e.Path)
+	}
+	eq(t, paths, []string{"index.html", "static/app.js", "static/copy.js"})
+	eq(t, m[0].Size, int64(13))
+	eq(t, m[0].Hash, "b633a587c652d02386c4f16f8c6f6aab7352d97f16367c3c40576214372dd628")
+	eq(t, m[0].Mode, os.FileMode(0644))
+	eq(t, m[1].Hash, m[2].Hash)
+	eq(t, len(m.ByHash()), 2)
+}
+
+func TestManifest_String(t *testing.T) {
+	m := Manifest{
+		{Path: "index.html", Size: 13, Mode: 0644, Hash: "b633"},
+		{Path: "odd\nname.js", Size: 0, Mode: 0755, Hash: "e3b0"},
+	}
+	eq(t, m.String(), "b633 13 0644 index.html\ne3b0 0 0755 \"odd\\nname.js\"\n")
+}
+
+func TestManifest_ChecksumIncludesNames(t *testing.T) {
+	a := makeDir(t, map[string]string{"a.js": "ab", "b.js": "c"})
+	defer os.RemoveAll(a)
+	b := makeDir(t, map[string]string{"a.js": "a", "b.js": "bc"})
+	defer os.RemoveAll(b)
+	c := makeDir(t, map[string]string{"c.js": "ab", "d.js": "c"})
+	defer os.RemoveAll(c)
+
+	checksums := map[string]bool{}
+	for _, dir := range []string{a, b, c} {
+		m, err := Build(dir)
+		eq(t, err, nil)
+		checksums[m.Checksum()] = true
+	}
+	eq(t, len(checksums), 3)
+
+	again, err := Build(a)
+	eq(t, err, nil)
+	first, _ := Build(a)
+	eq(t, again.Checksum(), first.Checksum())
+}
+
+func TestBuild_MissingDir(t *testing.T) {
+	_, err := Build(filepath.Join(os.TempDir(), "peek-does-not-exist"))
+	if err == nil {
+		t.Error("expected an error")
+	}
+}
+
+// Helper functions
+func makeDir(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "peek-manifest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range files {
+		path := filepath.Join(dir, filepath.FromSlash(name))
+		if err = os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func eq(t *testing.T, got interface{}, expected interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected: %v, got: %v", expected, got)
+	}
+}
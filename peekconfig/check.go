@@ -0,0 +1,66 @@
+package peekconfig
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ServiceReport is the result of checking a service against the files in the repository
+type ServiceReport struct {
+	Name     string   `json:"name"`
+	Type     string   `json:"type"`
+	Path     string   `json:"path,omitempty"`
+	Problems []string `json:"problems"`
+}
+
+// OK reports whether the service passed every check
+func (r ServiceReport) OK() bool {
+	return len(r.Problems) == 0
+}
+
+// CheckServices verifies that each static service's path exists inside rootDir
+// and holds an index.html. Reports are sorted by service name.
+func CheckServices(project *Project, rootDir string) []ServiceReport {
+	reports := []ServiceReport{}
+	for _, name := range project.ServiceNames() {
+		service := project.Services[name]
+		report := ServiceReport{
+			Name:     name,
+			Type:     service.Type,
+			Path:     service.Path,
+			Problems: []string{},
+		}
+		if service.Type == "static" {
+			report.Problems = append(report.Problems, checkStaticPath(rootDir, service.Path)...)
+		}
+		reports = append(reports, report)
+	}
+	return reports
+}
+
+func checkStaticPath(rootDir string, path string) []string {
+	fullPath := filepath.Join(rootDir, filepath.FromSlash(path))
+	rel, err := filepath.Rel(rootDir, fullPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return []string{fmt.Sprintf("path %q is outside the repository", path)}
+	}
+
+	info, err := os.Stat(fullPath)
+	if os.IsNotExist(err) {
+		return []string{fmt.Sprintf("path %q does not exist; run your build first", path)}
+	}
+	if err != nil {
+		return []string{err.Error()}
+	}
+	if !info.IsDir() {
+		return []string{fmt.Sprintf("path %q is not a directory", path)}
+	}
+
+	index, err := os.Stat(filepath.Join(fullPath, "index.html"))
+	if err != nil || !index.Mode().IsRegular() {
+		return []string{fmt.Sprintf("path %q does not contain an index.html", path)}
+	}
+	return nil
+}
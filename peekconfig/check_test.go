@@ -0,0 +1,53 @@
+package peekconfig
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCheckServices(t *testing.T) {
+	rootDir, err := ioutil.TempDir("", "peek-check")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(rootDir)
+
+	os.MkdirAll(filepath.Join(rootDir, "web", "build"), 0755)
+	ioutil.WriteFile(filepath.Join(rootDir, "web", "build", "index.html"), []byte("<html></html>"), 0644)
+	os.MkdirAll(filepath.Join(rootDir, "docs", "public"), 0755)
+
+	project, err := ParseProject([]byte(`version: 2
+web:
+  type: static
+  path: web/build
+docs:
+  type: static
+  path: docs/public
+admin:
+  type: static
+  path: admin/dist
+escape:
+  type: static
+  path: ../elsewhere
+api:
+  type: docker
+  port: 80
+`))
+	eq(t, err, nil)
+
+	reports := CheckServices(project, rootDir)
+	problems := map[string][]string{}
+	for _, r := range reports {
+		problems[r.Name] = r.Problems
+	}
+	eq(t, problems, map[string][]string{
+		"admin":  {`path "admin/dist" does not exist; run your build first`},
+		"api":    {},
+		"docs":   {`path "docs/public" does not contain an index.html`},
+		"escape": {`path "../elsewhere" is outside the repository`},
+		"web":    {},
+	})
+	eq(t, reports[0].Name, "admin")
+}
@@ -0,0 +1,131 @@
+package peekconfig
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Framework is a static site toolchain recognized in a project directory, with
+// the output directory and SPA setting it uses by default
+type Framework struct {
+	Name string
+	Path string
+	Spa  bool
+}
+
+// packageJSON holds the parts of package.json used for detection
+type packageJSON struct {
+	Dependencies    map[string]string `json:"dependencies"`
+	DevDependencies map[string]string `json:"devDependencies"`
+	Scripts         map[string]string `json:"scripts"`
+}
+
+func (p packageJSON) has(dependency string) bool {
+	_, dep := p.Dependencies[dependency]
+	_, devDep := p.DevDependencies[dependency]
+	return dep || devDep
+}
+
+// DetectFramework looks for framework config files in dir and returns the
+// first framework recognized, or nil if there is none
+func DetectFramework(dir string) *Framework {
+	if f := detectAngular(dir); f != nil {
+		return f
+	}
+
+	var pkg packageJSON
+	if data, err := ioutil.ReadFile(filepath.Join(dir, "package.json")); err == nil && json.Unmarshal(data, &pkg) == nil {
+		switch {
+		case pkg.has("next"):
+			return &Framework{Name: "Next.js (static export)", Path: "out", Spa: false}
+		case pkg.has("vite"):
+			return &Framework{Name: "Vite", Path: "dist", Spa: true}
+		case pkg.has("react-scripts"):
+			return &Framework{Name: "Create React App", Path: "build", Spa: true}
+		case pkg.has("@vue/cli-service"):
+			return &Framework{Name: "Vue CLI", Path: "dist", Spa: true}
+		case pkg.has("gatsby"):
+			return &Framework{Name: "Gatsby", Path: "public", Spa: false}
+		}
+	}
+
+	for _, name := range []string{"hugo.toml", "hugo.yaml", "hugo.json"} {
+		if fileExists(filepath.Join(dir, name)) {
+			return &Framework{Name: "Hugo", Path: "public", Spa: false}
+		}
+	}
+	if fileExists(filepath.Join(dir, "config.toml")) && dirExists(filepath.Join(dir, "content")) {
+		return &Framework{Name: "Hugo", Path: "public", Spa: false}
+	}
+
+	if fileExists(filepath.Join(dir, "_config.yml")) {
+		return &Framework{Name: "Jekyll", Path: "_site", Spa: false}
+	}
+
+	return nil
+}
+
+// detectAngular reads the build output path of the default project from angular.json
+func detectAngular(dir string) *Framework {
+	data, err := ioutil.ReadFile(filepath.Join(dir, "angular.json"))
+	if err != nil {
+		return nil
+	}
+
+	var workspace struct {
+		DefaultProject string `json:"defaultProject"`
+		Projects       map[string]struct {
+			Architect struct {
+				Build struct {
+					Options struct {
+						OutputPath interface{} `json:"outputPath"`
+					} `json:"options"`
+				} `json:"build"`
+			} `json:"architect"`
+		} `json:"projects"`
+	}
+	framework := &Framework{Name: "Angular", Path: "dist", Spa: true}
+	if err = json.Unmarshal(data, &workspace); err != nil {
+		return framework
+	}
+
+	project := workspace.DefaultProject
+	if _, ok := workspace.Projects[project]; !ok {
+		var names []string
+		for name := range workspace.Projects {
+			names = append(names, name)
+		}
+		if len(names) == 0 {
+			return framework
+		}
+		sort.Strings(names)
+		project = names[0]
+	}
+
+	// outputPath is a string, or an object with a base directory since Angular 17
+	switch outputPath := workspace.Projects[project].Architect.Build.Options.OutputPath.(type) {
+	case string:
+		framework.Path = strings.TrimSuffix(filepath.ToSlash(outputPath), "/")
+	case map[string]interface{}:
+		if base, ok := outputPath["base"].(string); ok {
+			framework.Path = strings.TrimSuffix(filepath.ToSlash(base), "/") + "/browser"
+		}
+	default:
+		framework.Path = "dist/" + project
+	}
+	return framework
+}
+
+func fileExists(filename string) bool {
+	info, err := os.Stat(filename)
+	return err == nil && !info.IsDir()
+}
+
+func dirExists(filename string) bool {
+	info, err := os.Stat(filename)
+	return err == nil && info.IsDir()
+}
@@ -0,0 +1,75 @@
+package peekconfig
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDetectFramework(t *testing.T) {
+	cases := []struct {
+		Label    string
+		Files    map[string]string
+		Expected *Framework
+	}{
+		{
+			Label:    "create react app",
+			Files:    map[string]string{"package.json": `{"dependencies": {"react": "^16", "react-scripts": "3.4.1"}}`},
+			Expected: &Framework{Name: "Create React App", Path: "build", Spa: true},
+		},
+		{
+			Label:    "vite",
+			Files:    map[string]string{"package.json": `{"devDependencies": {"vite": "^5"}}`},
+			Expected: &Framework{Name: "Vite", Path: "dist", Spa: true},
+		},
+		{
+			Label:    "next export",
+			Files:    map[string]string{"package.json": `{"dependencies": {"next": "^9", "react": "^16"}}`},
+			Expected: &Framework{Name: "Next.js (static export)", Path: "out", Spa: false},
+		},
+		{
+			Label:    "angular",
+			Files:    map[string]string{"angular.json": `{"defaultProject": "shop", "projects": {"shop": {"architect": {"build": {"options": {"outputPath": "dist/shop"}}}}}}`},
+			Expected: &Framework{Name: "Angular", Path: "dist/shop", Spa: true},
+		},
+		{
+			Label:    "angular 17",
+			Files:    map[string]string{"angular.json": `{"projects": {"shop": {"architect": {"build": {"options": {"outputPath": {"base": "dist/shop"}}}}}}}`},
+			Expected: &Framework{Name: "Angular", Path: "dist/shop/browser", Spa: true},
+		},
+		{
+			Label:    "hugo",
+			Files:    map[string]string{"hugo.toml": `title = "blog"`},
+			Expected: &Framework{Name: "Hugo", Path: "public", Spa: false},
+		},
+		{
+			Label:    "jekyll",
+			Files:    map[string]string{"_config.yml": `title: blog`},
+			Expected: &Framework{Name: "Jekyll", Path: "_site", Spa: false},
+		},
+		{
+			Label:    "nothing recognized",
+			Files:    map[string]string{"package.json": `{"dependencies": {"lodash": "^4"}}`},
+			Expected: nil,
+		},
+	}
+
+	for _, c := range cases {
+		dir, err := ioutil.TempDir("", "peek-detect")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for name, content := range c.Files {
+			ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		}
+
+		framework := DetectFramework(dir)
+		if c.Expected == nil && framework != nil {
+			t.Errorf("%s: expected no framework, got %+v", c.Label, framework)
+		} else if c.Expected != nil {
+			eq(t, framework, c.Expected)
+		}
+		os.RemoveAll(dir)
+	}
+}
@@ -1,42 +1,41 @@
 package peekconfig
 
 import (
+	"bytes"
 	"fmt"
 	"io/ioutil"
 	"os"
+	"strings"
 
-	"gopkg.in/yaml.v2"
+	"gopkg.in/yaml.v3"
 )
 
 const configFile string = "peek.yml"
 
 // Service defines the configuration options for an individual FeaturePeek service
 type Service struct {
-	Type string
-	Path string
-	Spa  bool
+	Type    string
+	Path    string `yaml:",omitempty"`
+	Spa     bool
+	Default bool              `yaml:",omitempty"`
+	Build   string            `yaml:",omitempty"`
+	Env     map[string]string `yaml:",omitempty"`
 }
 
 // Config defines the configuration options for a FeaturePeek project
 type Config struct {
-	Version int
-	Main    Service
+	Version  int
+	Services map[string]Service `yaml:",inline"`
 }
 
-// Save will marshal and save the peek.yml config to disk
-func (c Config) Save() (err error) {
-	fullConfigFile := configFile
-	// fullConfigFile, err := GetConfigFilePath()
-	// if err != nil {
-	// 	return err
-	// }
-
-	data, err := yaml.Marshal(c)
+// Save will marshal and save the peek.yml config to filename
+func (c Config) Save(filename string) (err error) {
+	data, err := encodeYAML(c)
 	if err != nil {
 		return
 	}
 
-	if err = ioutil.WriteFile(fullConfigFile, data, 0644); err != nil {
+	if err = ioutil.WriteFile(filename, data, 0644); err != nil {
 		return
 	}
 
@@ -45,60 +44,153 @@ func (c Config) Save() (err error) {
 
 // SimpleService is a simple representation of a service with a dynamic name
 type SimpleService struct {
-	Path string
-	Name string
+	Path  string
+	Name  string
+	Spa   bool
+	Build string
+	Env   map[string]string
 }
 
-// LoadStaticServiceFromFile attempts to load a specific static service from the peek.yml file.
-func LoadStaticServiceFromFile(filename string, serviceName string) (*SimpleService, error) {
-	data, err := ReadConfigFile(filename)
-	if err != nil {
-		return nil, err
+// ServiceNotFoundError is returned when a service requested by name is not a static service in peek.yml
+type ServiceNotFoundError struct {
+	Name       string
+	Type       string
+	Candidates []string
+}
+
+func (e *ServiceNotFoundError) Error() string {
+	var msg string
+	if e.Type != "" {
+		msg = fmt.Sprintf("service %q is a %s service, not a static service", e.Name, e.Type)
+	} else {
+		msg = fmt.Sprintf("service %q not found in peek.yml", e.Name)
 	}
+	if len(e.Candidates) > 0 {
+		msg += fmt.Sprintf(" (static services: %s)", strings.Join(e.Candidates, ", "))
+	}
+	return msg
+}
 
-	var config map[string]interface{}
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, err
+// AmbiguousServiceError is returned when several static services exist and none is marked as the default
+type AmbiguousServiceError struct {
+	Candidates []string
+	Defaults   []string
+}
+
+func (e *AmbiguousServiceError) Error() string {
+	if len(e.Defaults) > 1 {
+		return fmt.Sprintf("more than one service in peek.yml is marked `default: true`: %s", strings.Join(e.Defaults, ", "))
 	}
+	return fmt.Sprintf("peek.yml has multiple static services: %s\nChoose one with --service, or mark one with `default: true`", strings.Join(e.Candidates, ", "))
+}
 
-	var service *SimpleService
+// staticServices holds the services parsed out of a peek.yml file
+type staticServices struct {
+	byName   map[string]*SimpleService
+	types    map[string]string
+	names    []string
+	defaults []string
+}
 
-	for k, v := range config {
-		if k == "version" {
+func parseStaticServices(filename string) (*staticServices, error) {
+	project, err := LoadProject(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed := &staticServices{
+		byName: make(map[string]*SimpleService),
+		types:  make(map[string]string),
+	}
+	for _, name := range project.ServiceNames() {
+		service := project.Services[name]
+		parsed.types[name] = service.Type
+		if service.Type != "static" {
 			continue
 		}
 
-		s, ok := v.(map[interface{}]interface{})
-		if !ok {
-			continue
+		parsed.byName[name] = &SimpleService{
+			Name:  name,
+			Path:  service.Path,
+			Spa:   service.Spa,
+			Build: service.Build,
+			Env:   service.Env,
+		}
+		parsed.names = append(parsed.names, name)
+		if service.Default {
+			parsed.defaults = append(parsed.defaults, name)
 		}
+	}
 
-		serviceConfig := make(map[string]string)
-		for sk, sv := range s {
-			strKey := fmt.Sprintf("%v", sk)
-			strVal := fmt.Sprintf("%v", sv)
+	return parsed, nil
+}
 
-			serviceConfig[strKey] = strVal
-		}
+func (s *staticServices) find(name string) (*SimpleService, error) {
+	if service, ok := s.byName[name]; ok {
+		return service, nil
+	}
+	return nil, &ServiceNotFoundError{
+		Name:       name,
+		Type:       s.types[name],
+		Candidates: s.names,
+	}
+}
 
-		serviceType, ok := serviceConfig["type"]
-		if !ok {
-			continue
-		}
-		servicePath, ok := serviceConfig["path"]
-		if !ok {
-			continue
-		}
+// LoadStaticServiceFromFile attempts to load a specific static service from the peek.yml file.
+//
+// Without a service name, the only static service is used. If there are several,
+// the one marked `default: true` is chosen, then one named `main`; otherwise an
+// *AmbiguousServiceError lists the candidates. A name that does not match a static
+// service returns a *ServiceNotFoundError. If peek.yml has no static services at
+// all, the service returned is nil.
+func LoadStaticServiceFromFile(filename string, serviceName string) (*SimpleService, error) {
+	services, err := parseStaticServices(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if serviceName != "" {
+		return services.find(serviceName)
+	}
 
-		if serviceType == "static" && (serviceName == "" || serviceName == k) {
-			service = new(SimpleService)
-			service.Name = k
-			service.Path = servicePath
-			break
+	switch {
+	case len(services.names) == 0:
+		return nil, nil
+	case len(services.names) == 1:
+		return services.byName[services.names[0]], nil
+	case len(services.defaults) == 1:
+		return services.byName[services.defaults[0]], nil
+	case len(services.defaults) > 1:
+		return nil, &AmbiguousServiceError{Candidates: services.names, Defaults: services.defaults}
+	}
+	if service, ok := services.byName["main"]; ok {
+		return service, nil
+	}
+	return nil, &AmbiguousServiceError{Candidates: services.names}
+}
+
+// LoadStaticServicesFromFile loads the named static services from the peek.yml file,
+// in the order given. If no names are given, every static service is returned, sorted by name.
+func LoadStaticServicesFromFile(filename string, serviceNames []string) ([]*SimpleService, error) {
+	services, err := parseStaticServices(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(serviceNames) == 0 {
+		serviceNames = services.names
+	}
+
+	var selected []*SimpleService
+	for _, name := range serviceNames {
+		service, err := services.find(name)
+		if err != nil {
+			return nil, err
 		}
+		selected = append(selected, service)
 	}
 
-	return service, nil
+	return selected, nil
 }
 
 // LoadFromFile attempts to populate a SimpleService struct from the given peek.yml file.
@@ -121,3 +213,16 @@ var ReadConfigFile = func(filename string) ([]byte, error) {
 
 	return data, nil
 }
+
+func encodeYAML(v interface{}) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -96,6 +96,148 @@ main:
 	eq(t, service.Path, path)
 }
 
+func TestLoadStaticServicesFromFile_All(t *testing.T) {
+	defer StubConfig(`---
+version: 2
+
+web:
+  type: static
+  path: web/build
+
+api:
+  type: docker
+  port: 80
+
+admin:
+  type: static
+  path: admin/dist
+`)()
+	services, err := LoadStaticServicesFromFile("apeekdotyaml", nil)
+	eq(t, err, nil)
+	eq(t, services, []*SimpleService{
+		{Name: "admin", Path: "admin/dist"},
+		{Name: "web", Path: "web/build"},
+	})
+}
+
+func TestLoadStaticServicesFromFile_Named(t *testing.T) {
+	defer StubConfig(`---
+version: 2
+
+web:
+  type: static
+  path: web/build
+
+api:
+  type: docker
+  port: 80
+
+docs:
+  type: static
+  path: docs/public
+`)()
+	services, err := LoadStaticServicesFromFile("apeekdotyaml", []string{"web", "docs"})
+	eq(t, err, nil)
+	eq(t, services, []*SimpleService{
+		{Name: "web", Path: "web/build"},
+		{Name: "docs", Path: "docs/public"},
+	})
+
+	_, err = LoadStaticServicesFromFile("apeekdotyaml", []string{"web", "admin"})
+	eq(t, err.Error(), `service "admin" not found in peek.yml (static services: docs, web)`)
+
+	_, err = LoadStaticServicesFromFile("apeekdotyaml", []string{"api"})
+	eq(t, err.Error(), `service "api" is a docker service, not a static service (static services: docs, web)`)
+}
+
+const multipleStaticServices = `---
+version: 2
+
+web:
+  type: static
+  path: web/build
+%s
+admin:
+  type: static
+  path: admin/dist
+%s
+docs:
+  type: static
+  path: docs/public
+`
+
+func TestLoadStaticServiceFromFile_Ambiguous(t *testing.T) {
+	defer StubConfig(fmt.Sprintf(multipleStaticServices, "", ""))()
+	for i := 0; i < 10; i++ {
+		service, err := LoadStaticServiceFromFile("apeekdotyaml", "")
+		var nilService *SimpleService
+		eq(t, service, nilService)
+		if _, ok := err.(*AmbiguousServiceError); !ok {
+			t.Fatalf("expected an AmbiguousServiceError, got %v", err)
+		}
+		eq(t, err.(*AmbiguousServiceError).Candidates, []string{"admin", "docs", "web"})
+	}
+}
+
+func TestLoadStaticServiceFromFile_Default(t *testing.T) {
+	defer StubConfig(fmt.Sprintf(multipleStaticServices, "", "  default: true\n"))()
+	service, err := LoadStaticServiceFromFile("apeekdotyaml", "")
+	eq(t, err, nil)
+	eq(t, service, &SimpleService{Name: "admin", Path: "admin/dist"})
+}
+
+func TestLoadStaticServiceFromFile_MultipleDefaults(t *testing.T) {
+	defer StubConfig(fmt.Sprintf(multipleStaticServices, "  default: true\n", "  default: true\n"))()
+	_, err := LoadStaticServiceFromFile("apeekdotyaml", "")
+	eq(t, err, &AmbiguousServiceError{
+		Candidates: []string{"admin", "docs", "web"},
+		Defaults:   []string{"admin", "web"},
+	})
+}
+
+func TestLoadStaticServiceFromFile_PrefersMain(t *testing.T) {
+	defer StubConfig(`---
+version: 2
+
+web:
+  type: static
+  path: web/build
+
+main:
+  type: static
+  path: build
+`)()
+	service, err := LoadStaticServiceFromFile("apeekdotyaml", "")
+	eq(t, err, nil)
+	eq(t, service, &SimpleService{Name: "main", Path: "build"})
+}
+
+func TestLoadStaticServiceFromFile_UnknownService(t *testing.T) {
+	defer StubConfig(fmt.Sprintf(multipleStaticServices, "", ""))()
+	_, err := LoadStaticServiceFromFile("apeekdotyaml", "blog")
+	eq(t, err, &ServiceNotFoundError{Name: "blog", Candidates: []string{"admin", "docs", "web"}})
+}
+
+func TestConfig_Marshal(t *testing.T) {
+	data, err := encodeYAML(Config{
+		Version: 2,
+		Services: map[string]Service{
+			"web": {Type: "static", Path: "build", Spa: true},
+		},
+	})
+	eq(t, err, nil)
+	eq(t, string(data), `version: 2
+web:
+  type: static
+  path: build
+  spa: true
+`)
+
+	project, err := ParseProject(data)
+	eq(t, err, nil)
+	eq(t, project.Services["web"], &Service{Type: "static", Path: "build", Spa: true})
+}
+
 // Helper functions
 func StubConfig(content string) func() {
 	orig := ReadConfigFile
@@ -0,0 +1,308 @@
+package peekconfig
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// CurrentVersion is the peek.yml schema version written by this CLI
+const CurrentVersion = 2
+
+// Project is a validated peek.yml
+type Project struct {
+	Version  int
+	Services map[string]*Service
+}
+
+// ServiceNames returns the names of all services, sorted
+func (p *Project) ServiceNames() []string {
+	names := make([]string, 0, len(p.Services))
+	for name := range p.Services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// ValidationError describes a problem at a position in peek.yml
+type ValidationError struct {
+	Line    int    `json:"line"`
+	Column  int    `json:"column"`
+	Message string `json:"message"`
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s:%d:%d: %s", configFile, e.Line, e.Column, e.Message)
+}
+
+// ValidationErrors is every problem found while loading peek.yml
+type ValidationErrors []*ValidationError
+
+func (e ValidationErrors) Error() string {
+	msgs := make([]string, len(e))
+	for i, err := range e {
+		msgs[i] = err.Error()
+	}
+	return strings.Join(msgs, "\n")
+}
+
+// serviceKeys lists the keys peek reads for each service type and the YAML tag each value must have
+var serviceKeys = map[string]map[string]string{
+	"static": {
+		"type":    "!!str",
+		"path":    "!!str",
+		"spa":     "!!bool",
+		"default": "!!bool",
+		"build":   "!!str",
+		"env":     "!!map",
+	},
+	"docker": {
+		"type":    "!!str",
+		"default": "!!bool",
+	},
+}
+
+// openServiceTypes are the service types whose options are read by FeaturePeek
+// rather than peek, so keys missing from serviceKeys are passed over
+var openServiceTypes = map[string]bool{"docker": true}
+
+var serviceTypes = []string{"docker", "static"}
+
+var tagNames = map[string]string{
+	"!!str":  "a string",
+	"!!bool": "true or false",
+	"!!int":  "an integer",
+	"!!map":  "a mapping",
+	"!!seq":  "a list",
+	"!!null": "empty",
+}
+
+// ParseProject parses and validates the contents of a peek.yml file. An empty
+// file yields a project with no services.
+func ParseProject(data []byte) (*Project, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return &Project{Version: CurrentVersion, Services: map[string]*Service{}}, nil
+	}
+
+	root := doc.Content[0]
+	if err := checkVersion(root); err != nil {
+		return nil, err
+	}
+	return decodeProject(root)
+}
+
+// checkVersion makes sure a peek.yml document declares CurrentVersion, the
+// only schema version there is
+func checkVersion(root *yaml.Node) error {
+	if root.Kind != yaml.MappingNode {
+		// leave the error to validation
+		return nil
+	}
+
+	versionNode := mappingValue(root, "version")
+	if versionNode == nil {
+		return ValidationErrors{{Line: root.Line, Column: root.Column, Message: `missing required key "version"`}}
+	}
+	var version int
+	if err := versionNode.Decode(&version); err != nil || versionNode.Tag != "!!int" {
+		return ValidationErrors{{Line: versionNode.Line, Column: versionNode.Column, Message: "version must be an integer"}}
+	}
+
+	if version > CurrentVersion {
+		return ValidationErrors{{Line: versionNode.Line, Column: versionNode.Column,
+			Message: fmt.Sprintf("version %d is newer than this peek supports (%d); upgrade peek", version, CurrentVersion)}}
+	}
+	if version < CurrentVersion {
+		return ValidationErrors{{Line: versionNode.Line, Column: versionNode.Column,
+			Message: fmt.Sprintf("unknown version %d", version)}}
+	}
+	return nil
+}
+
+// LoadProject reads and validates the peek.yml file at filename
+func LoadProject(filename string) (*Project, error) {
+	data, err := ReadConfigFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	return ParseProject(data)
+}
+
+func decodeProject(root *yaml.Node) (*Project, error) {
+	var errs ValidationErrors
+	fail := func(n *yaml.Node, format string, args ...interface{}) {
+		errs = append(errs, &ValidationError{Line: n.Line, Column: n.Column, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if root.Kind != yaml.MappingNode {
+		fail(root, "expected a mapping of services, got %s", tagName(root))
+		return nil, errs
+	}
+
+	project := &Project{Services: map[string]*Service{}}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		key, value := root.Content[i], root.Content[i+1]
+
+		if key.Value == "version" {
+			if err := value.Decode(&project.Version); err != nil || value.Tag != "!!int" {
+				fail(value, "version must be an integer, got %s", tagName(value))
+			}
+			continue
+		}
+
+		if value.Kind != yaml.MappingNode {
+			fail(value, "service %q must be a mapping, got %s", key.Value, tagName(value))
+			continue
+		}
+
+		service := &Service{}
+		serviceType := mappingValue(value, "type")
+		if serviceType == nil {
+			fail(value, "service %q is missing required key \"type\"", key.Value)
+			continue
+		}
+		allowed, ok := serviceKeys[serviceType.Value]
+		if !ok {
+			fail(serviceType, "service %q has unknown type %q (expected one of: %s)", key.Value, serviceType.Value, strings.Join(serviceTypes, ", "))
+			continue
+		}
+		service.Type = serviceType.Value
+
+		for j := 0; j+1 < len(value.Content); j += 2 {
+			field, fieldValue := value.Content[j], value.Content[j+1]
+			tag, ok := allowed[field.Value]
+			if !ok && openServiceTypes[service.Type] {
+				continue
+			}
+			if !ok {
+				msg := fmt.Sprintf("unknown key %q in %s service %q", field.Value, service.Type, key.Value)
+				if suggestion := closest(field.Value, sortedKeys(allowed)); suggestion != "" {
+					msg += fmt.Sprintf(" (did you mean %q?)", suggestion)
+				}
+				fail(field, "%s", msg)
+				continue
+			}
+			if fieldValue.Tag != tag {
+				fail(fieldValue, "%q in service %q must be %s, got %s", field.Value, key.Value, tagNames[tag], tagName(fieldValue))
+				continue
+			}
+			errCount := len(errs)
+			if field.Value == "env" {
+				for k := 0; k+1 < len(fieldValue.Content); k += 2 {
+					if envValue := fieldValue.Content[k+1]; envValue.Kind != yaml.ScalarNode || envValue.Tag == "!!null" {
+						fail(envValue, "env %q in service %q must be a string, got %s", fieldValue.Content[k].Value, key.Value, tagName(envValue))
+					}
+				}
+			}
+			if len(errs) > errCount {
+				continue
+			}
+			if err := fieldValue.Decode(serviceField(service, field.Value)); err != nil {
+				fail(fieldValue, "%q in service %q: %v", field.Value, key.Value, err)
+			}
+		}
+
+		if service.Type == "static" && mappingValue(value, "path") == nil {
+			fail(value, "static service %q is missing required key \"path\"", key.Value)
+		}
+
+		project.Services[key.Value] = service
+	}
+
+	if len(errs) > 0 {
+		return nil, errs
+	}
+	return project, nil
+}
+
+// serviceField returns a pointer to the Service field for a peek.yml key
+func serviceField(s *Service, key string) interface{} {
+	switch key {
+	case "type":
+		return &s.Type
+	case "path":
+		return &s.Path
+	case "spa":
+		return &s.Spa
+	case "default":
+		return &s.Default
+	case "build":
+		return &s.Build
+	case "env":
+		return &s.Env
+	}
+	panic("peekconfig: no field for key " + key)
+}
+
+func mappingValue(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func tagName(n *yaml.Node) string {
+	if name, ok := tagNames[n.ShortTag()]; ok {
+		return name
+	}
+	return n.ShortTag()
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// closest returns the candidate within two edits of s, if any
+func closest(s string, candidates []string) string {
+	best, bestDistance := "", 3
+	for _, c := range candidates {
+		if d := editDistance(s, c); d < bestDistance {
+			best, bestDistance = c, d
+		}
+	}
+	return best
+}
+
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur := make([]int, len(b)+1)
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(b)]
+}
+
+func minInt(values ...int) int {
+	m := values[0]
+	for _, v := range values[1:] {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
@@ -0,0 +1,121 @@
+package peekconfig
+
+import (
+	"testing"
+)
+
+func TestParseProject_Valid(t *testing.T) {
+	project, err := ParseProject([]byte(`---
+version: 2
+
+web:
+  type: static
+  path: build
+  spa: true
+  default: true
+
+api:
+  type: docker
+  port: 80
+`))
+	eq(t, err, nil)
+	eq(t, project.Version, 2)
+	eq(t, project.ServiceNames(), []string{"api", "web"})
+	eq(t, project.Services["web"], &Service{Type: "static", Path: "build", Spa: true, Default: true})
+	eq(t, project.Services["api"], &Service{Type: "docker"})
+}
+
+func TestParseProject_Build(t *testing.T) {
+	project, err := ParseProject([]byte(`version: 2
+web:
+  type: static
+  path: dist
+  build: npm run build
+  env:
+    NODE_ENV: production
+    PORT: 3000
+`))
+	eq(t, err, nil)
+	eq(t, project.Services["web"], &Service{
+		Type:  "static",
+		Path:  "dist",
+		Build: "npm run build",
+		Env:   map[string]string{"NODE_ENV": "production", "PORT": "3000"},
+	})
+
+	_, err = ParseProject([]byte(`version: 2
+web:
+  type: static
+  path: dist
+  build: [npm, run, build]
+  env:
+    FLAGS: [a, b]
+`))
+	eq(t, err.Error(), `peek.yml:5:10: "build" in service "web" must be a string, got a list
+peek.yml:7:12: env "FLAGS" in service "web" must be a string, got a list`)
+}
+
+func TestParseProject_Errors(t *testing.T) {
+	_, err := ParseProject([]byte(`version: 2
+web:
+  type: static
+  pth: build
+  spa: yes please
+admin:
+  type: lambda
+docs: build
+`))
+	errs, ok := err.(ValidationErrors)
+	if !ok {
+		t.Fatalf("expected ValidationErrors, got %v", err)
+	}
+	eq(t, err.Error(), `peek.yml:4:3: unknown key "pth" in static service "web" (did you mean "path"?)
+peek.yml:5:8: "spa" in service "web" must be true or false, got a string
+peek.yml:3:3: static service "web" is missing required key "path"
+peek.yml:7:9: service "admin" has unknown type "lambda" (expected one of: docker, static)
+peek.yml:8:7: service "docs" must be a mapping, got a string`)
+	eq(t, errs[0].Line, 4)
+	eq(t, errs[0].Column, 3)
+}
+
+func TestParseProject_MissingVersion(t *testing.T) {
+	_, err := ParseProject([]byte(`web:
+  type: static
+  path: build
+`))
+	eq(t, err.Error(), `peek.yml:1:1: missing required key "version"`)
+}
+
+func TestParseProject_NewerVersion(t *testing.T) {
+	_, err := ParseProject([]byte(`version: 3
+`))
+	eq(t, err.Error(), `peek.yml:1:10: version 3 is newer than this peek supports (2); upgrade peek`)
+}
+
+func TestParseProject_UnknownOlderVersion(t *testing.T) {
+	_, err := ParseProject([]byte(`version: 1
+main:
+  type: static
+  path: dist
+`))
+	eq(t, err.Error(), `peek.yml:1:10: unknown version 1`)
+}
+
+func TestParseProject_DockerAcceptsAnyKey(t *testing.T) {
+	project, err := ParseProject([]byte(`version: 2
+api:
+  type: docker
+  default: true
+  port: 80
+  healthcheck:
+    path: /ping
+`))
+	eq(t, err, nil)
+	eq(t, project.Services["api"], &Service{Type: "docker", Default: true})
+
+	_, err = ParseProject([]byte(`version: 2
+api:
+  port: 80
+`))
+	eq(t, err.Error(), `peek.yml:3:3: service "api" is missing required key "type"`)
+}
@@ -0,0 +1,56 @@
+package upload
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/mholt/archiver/v3"
+)
+
+// WriteArchive streams a tar.gz archive of the contents of dir to w.
+// Entries are named relative to dir, so the directory itself is not included.
+func WriteArchive(w io.Writer, dir string) error {
+	tgz := archiver.NewTarGz()
+	if err := tgz.Create(w); err != nil {
+		return err
+	}
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+
+		var file io.ReadCloser
+		if info.Mode().IsRegular() {
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			file = f
+		}
+
+		return tgz.Write(archiver.File{
+			FileInfo: archiver.FileInfo{
+				FileInfo:   info,
+				CustomName: filepath.ToSlash(name),
+			},
+			ReadCloser: file,
+		})
+	})
+	if err != nil {
+		tgz.Close()
+		return err
+	}
+
+	return tgz.Close()
+}
@@ -0,0 +1,307 @@
+package upload
+
+import (
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DefaultChunkSize is the size of each chunk sent by a ChunkedUploader
+const DefaultChunkSize int64 = 8 << 20
+
+// ChunkedUploader sends an archive in fixed-size chunks so that an interrupted
+// upload can be resumed from the last chunk the server acknowledged.
+//
+// The protocol, relative to URL, is:
+//
+//	POST /uploads                  form fields, size and chunk_size; replies {"id": "..."}
+//	PUT  /uploads/{id}/chunks/{n}  raw chunk bytes with a Content-Range header
+//	POST /uploads/{id}/complete    replies like a single-request upload
+type ChunkedUploader struct {
+	Client    *http.Client
+	URL       string
+	Header    HeaderFunc
+	ChunkSize int64
+	Retry     Retry
+	// StateDir holds the spooled archive and progress of unfinished uploads
+	StateDir string
+}
+
+// Session records the progress of a chunked upload on disk
+type Session struct {
+	ID        string `json:"id"`
+	Size      int64  `json:"size"`
+	ChunkSize int64  `json:"chunk_size"`
+	Acked     int    `json:"acked"`
+}
+
+// Chunks returns the total number of chunks in the upload
+func (s Session) Chunks() int {
+	if s.Size == 0 {
+		return 1
+	}
+	return int((s.Size + s.ChunkSize - 1) / s.ChunkSize)
+}
+
+// Upload sends the form's archive in chunks, resuming a previous attempt for
+// the same fields and archive contents if one was interrupted. It returns the
+// status code and body of the final completion request. If the server no
+// longer knows the session being resumed, the upload starts over.
+func (u *ChunkedUploader) Upload(form *Form) (int, []byte, error) {
+	if err := os.MkdirAll(u.StateDir, 0700); err != nil {
+		return 0, nil, err
+	}
+
+	key := sessionKey(form)
+	pruneSessions(u.StateDir, key, time.Now())
+	archivePath := filepath.Join(u.StateDir, key+".tar.gz")
+	statePath := filepath.Join(u.StateDir, key+".json")
+
+	session, err := loadSession(statePath, archivePath)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	status, body, err := u.upload(form, session, archivePath, statePath)
+	if sessionExpired(err) {
+		os.Remove(statePath)
+		os.Remove(archivePath)
+		if session != nil {
+			status, body, err = u.upload(form, nil, archivePath, statePath)
+			if sessionExpired(err) {
+				os.Remove(statePath)
+				os.Remove(archivePath)
+			}
+		}
+	}
+	return status, body, err
+}
+
+// upload sends the archive for a session, starting a new session if it is nil
+func (u *ChunkedUploader) upload(form *Form, session *Session, archivePath, statePath string) (int, []byte, error) {
+	if session == nil {
+		size, err := spoolArchive(archivePath, form.Dir)
+		if err != nil {
+			return 0, nil, err
+		}
+		session = &Session{Size: size, ChunkSize: u.chunkSize()}
+		if err = u.start(session, form); err != nil {
+			return 0, nil, err
+		}
+		if err = saveSession(statePath, session); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	archive, err := os.Open(archivePath)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer archive.Close()
+
+	for n := session.Acked; n < session.Chunks(); n++ {
+		if err = u.sendChunk(session, archive, n); err != nil {
+			return 0, nil, fmt.Errorf("uploading chunk %d of %d: %w", n+1, session.Chunks(), err)
+		}
+		session.Acked = n + 1
+		if err = saveSession(statePath, session); err != nil {
+			return 0, nil, err
+		}
+	}
+
+	var status int
+	var body []byte
+	err = u.Retry.Do(func() (err error) {
+		status, body, err = u.do("POST", u.sessionURL(session, "complete"), nil, nil)
+		return
+	})
+	if err != nil {
+		return status, body, err
+	}
+
+	os.Remove(statePath)
+	os.Remove(archivePath)
+	return status, body, nil
+}
+
+// sessionExpired reports whether the server has dropped or expired an upload session
+func sessionExpired(err error) bool {
+	var statusErr *StatusError
+	return errors.As(err, &statusErr) &&
+		(statusErr.StatusCode == http.StatusNotFound || statusErr.StatusCode == http.StatusGone)
+}
+
+// StaleSessionAge is how long an unfinished upload is kept on disk for resuming
+const StaleSessionAge = 24 * time.Hour
+
+// pruneSessions removes the spooled archives and progress of uploads, other
+// than the one for key, that have not been touched for StaleSessionAge. A
+// rebuild changes the session key, so abandoned sessions are never resumed.
+func pruneSessions(stateDir string, key string, now time.Time) {
+	files, err := ioutil.ReadDir(stateDir)
+	if err != nil {
+		return
+	}
+	for _, file := range files {
+		name := file.Name()
+		if file.IsDir() || strings.HasPrefix(name, key+".") {
+			continue
+		}
+		if !strings.HasSuffix(name, ".tar.gz") && !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		if now.Sub(file.ModTime()) > StaleSessionAge {
+			os.Remove(filepath.Join(stateDir, name))
+		}
+	}
+}
+
+func (u *ChunkedUploader) chunkSize() int64 {
+	if u.ChunkSize > 0 {
+		return u.ChunkSize
+	}
+	return DefaultChunkSize
+}
+
+func (u *ChunkedUploader) start(session *Session, form *Form) error {
+	data := url.Values{}
+	for _, field := range form.Fields {
+		data.Set(field.Name, field.Value)
+	}
+	data.Set("size", strconv.FormatInt(session.Size, 10))
+	data.Set("chunk_size", strconv.FormatInt(session.ChunkSize, 10))
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var body []byte
+	err := u.Retry.Do(func() (err error) {
+		_, body, err = u.do("POST", u.URL+"/uploads", []byte(data.Encode()), header)
+		return
+	})
+	if err != nil {
+		return err
+	}
+
+	var resp struct {
+		ID string `json:"id"`
+	}
+	if err = json.Unmarshal(body, &resp); err != nil {
+		return fmt.Errorf("unexpected response starting upload: %s", body)
+	}
+	if resp.ID == "" {
+		return fmt.Errorf("upload id missing from response: %s", body)
+	}
+	session.ID = resp.ID
+	return nil
+}
+
+func (u *ChunkedUploader) sendChunk(session *Session, archive io.ReaderAt, n int) error {
+	start := int64(n) * session.ChunkSize
+	length := session.ChunkSize
+	if start+length > session.Size {
+		length = session.Size - start
+	}
+
+	chunk := make([]byte, length)
+	if _, err := archive.ReadAt(chunk, start); err != nil && err != io.EOF {
+		return err
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/octet-stream")
+	if length > 0 {
+		header.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, start+length-1, session.Size))
+	}
+
+	return u.Retry.Do(func() error {
+		_, _, err := u.do("PUT", u.sessionURL(session, "chunks", strconv.Itoa(n)), chunk, header)
+		return err
+	})
+}
+
+func (u *ChunkedUploader) sessionURL(session *Session, parts ...string) string {
+	reqURL := u.URL + "/uploads/" + url.PathEscape(session.ID)
+	for _, part := range parts {
+		reqURL += "/" + part
+	}
+	return reqURL
+}
+
+func (u *ChunkedUploader) do(method, reqURL string, body []byte, header http.Header) (int, []byte, error) {
+	return doWith(u.Client, u.Header, method, reqURL, body, header)
+}
+
+// sessionKey identifies an upload by its fields and the directory it archives,
+// so a rerun for the same commit and build picks up where it left off
+func sessionKey(form *Form) string {
+	fields := make([]string, 0, len(form.Fields))
+	for _, field := range form.Fields {
+		fields = append(fields, field.Name+"="+field.Value)
+	}
+	sort.Strings(fields)
+
+	h := sha256.New()
+	fmt.Fprintln(h, form.Dir)
+	for _, field := range fields {
+		fmt.Fprintln(h, field)
+	}
+	return fmt.Sprintf("%x", h.Sum(nil))[:16]
+}
+
+func spoolArchive(filename string, dir string) (int64, error) {
+	f, err := os.OpenFile(filename, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0600)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	cw := &countingWriter{w: f}
+	if err = WriteArchive(cw, dir); err != nil {
+		os.Remove(filename)
+		return 0, err
+	}
+	return cw.n, nil
+}
+
+// loadSession returns the saved session, or nil if there is nothing to resume
+func loadSession(statePath string, archivePath string) (*Session, error) {
+	data, err := ioutil.ReadFile(statePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var session Session
+	if err = json.Unmarshal(data, &session); err != nil || session.ID == "" || session.ChunkSize <= 0 {
+		// unreadable state; start over
+		return nil, nil
+	}
+
+	info, err := os.Stat(archivePath)
+	if err != nil || info.Size() != session.Size {
+		return nil, nil
+	}
+	return &session, nil
+}
+
+func saveSession(statePath string, session *Session) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(statePath, data, 0600)
+}
@@ -0,0 +1,255 @@
+package upload
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// fakeChunkServer implements the chunked upload protocol in memory
+type fakeChunkServer struct {
+	mu       sync.Mutex
+	fields   map[string]string
+	chunks   map[int][]byte
+	puts     []int
+	failures map[int]int // chunk index -> status to reply once
+	fatal    map[int]bool
+	sessions int
+	expired  map[string]bool // session ids the server no longer knows
+	auths    []string        // Authorization header of each chunk
+}
+
+func (s *fakeChunkServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if r.Method == "POST" && r.URL.Path == "/peek/uploads" {
+		r.ParseForm()
+		s.fields = map[string]string{}
+		for k := range r.PostForm {
+			s.fields[k] = r.PostForm.Get(k)
+		}
+		s.sessions++
+		s.chunks = map[int][]byte{}
+		w.WriteHeader(http.StatusCreated)
+		fmt.Fprintf(w, `{"id": "up-%d"}`, s.sessions)
+		return
+	}
+
+	parts := strings.Split(strings.TrimPrefix(r.URL.Path, "/peek/uploads/"), "/")
+	if len(parts) < 2 || parts[0] != fmt.Sprintf("up-%d", s.sessions) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	if s.expired[parts[0]] {
+		w.WriteHeader(http.StatusGone)
+		return
+	}
+
+	switch {
+	case r.Method == "PUT" && len(parts) == 3 && parts[1] == "chunks":
+		n, _ := strconv.Atoi(parts[2])
+		s.puts = append(s.puts, n)
+		s.auths = append(s.auths, r.Header.Get("Authorization"))
+		if s.fatal[n] {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if status, ok := s.failures[n]; ok {
+			delete(s.failures, n)
+			w.WriteHeader(status)
+			return
+		}
+		data, _ := ioutil.ReadAll(r.Body)
+		s.chunks[n] = data
+		w.WriteHeader(http.StatusNoContent)
+	case r.Method == "POST" && parts[1] == "complete":
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("https://preview.example.com"))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *fakeChunkServer) assembled() []byte {
+	var data []byte
+	for n := 0; n < len(s.chunks); n++ {
+		data = append(data, s.chunks[n]...)
+	}
+	return data
+}
+
+func TestChunkedUploader_RetriesAndResumes(t *testing.T) {
+	dir := makeAssetDir(t)
+	defer os.RemoveAll(dir)
+	stateDir, err := ioutil.TempDir("", "peek-state")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(stateDir)
+
+	fake := &fakeChunkServer{
+		chunks:   map[int][]byte{},
+		failures: map[int]int{0: http.StatusServiceUnavailable},
+		fatal:    map[int]bool{2: true},
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	form := NewForm(dir)
+	form.AddField("app", "main")
+	form.AddField("sha", "abc123")
+
+	// each request asks for its headers, so a refreshed token is picked up mid-upload
+	tokens := 0
+	uploader := &ChunkedUploader{
+		URL: server.URL + "/peek",
+		Header: func() (http.Header, error) {
+			tokens++
+			return http.Header{"Authorization": []string{fmt.Sprintf("Bearer token-%d", tokens)}}, nil
+		},
+		ChunkSize: 64,
+		Retry:     Retry{Attempts: 3, Initial: time.Millisecond},
+		StateDir:  stateDir,
+	}
+
+	// first run is interrupted by a non-retryable failure on the third chunk
+	if _, _, err = uploader.Upload(form); err == nil {
+		t.Fatal("expected the first upload to fail")
+	}
+	eq(t, fake.puts, []int{0, 0, 1, 2})
+	eq(t, fake.auths, []string{"Bearer token-2", "Bearer token-3", "Bearer token-4", "Bearer token-5"})
+	eq(t, fake.fields["app"], "main")
+	eq(t, fake.fields["sha"], "abc123")
+
+	// second run resumes from the last acknowledged chunk
+	fake.puts = nil
+	fake.fatal = nil
+	status, body, err := uploader.Upload(form)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	eq(t, status, http.StatusCreated)
+	eq(t, string(body), "https://preview.example.com")
+	if len(fake.puts) == 0 || fake.puts[0] != 2 {
+		t.Errorf("expected upload to resume at chunk 2, got %v", fake.puts)
+	}
+
+	archive := &bytes.Buffer{}
+	if err = WriteArchive(archive, dir); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(fake.assembled(), archive.Bytes()) {
+		t.Error("assembled chunks do not match the archive")
+	}
+
+	leftovers, _ := ioutil.ReadDir(stateDir)
+	eq(t, len(leftovers), 0)
+}
+
+func TestChunkedUploader_RestartsExpiredSession(t *testing.T) {
+	dir := makeAssetDir(t)
+	defer os.RemoveAll(dir)
+	stateDir := t.TempDir()
+
+	fake := &fakeChunkServer{fatal: map[int]bool{1: true}}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	form := NewForm(dir)
+	form.AddField("app", "main")
+
+	uploader := &ChunkedUploader{
+		URL:       server.URL + "/peek",
+		ChunkSize: 64,
+		Retry:     Retry{Attempts: 2, Initial: time.Millisecond},
+		StateDir:  stateDir,
+	}
+
+	if _, _, err := uploader.Upload(form); err == nil {
+		t.Fatal("expected the first upload to fail")
+	}
+
+	// the server drops the interrupted session; the rerun starts a new one
+	fake.fatal = nil
+	fake.expired = map[string]bool{"up-1": true}
+	fake.puts = nil
+	status, _, err := uploader.Upload(form)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	eq(t, status, http.StatusCreated)
+	eq(t, fake.sessions, 2)
+	eq(t, fake.puts[0], 0)
+
+	archive := &bytes.Buffer{}
+	if err = WriteArchive(archive, dir); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(fake.assembled(), archive.Bytes()) {
+		t.Error("assembled chunks do not match the archive")
+	}
+
+	leftovers, _ := ioutil.ReadDir(stateDir)
+	eq(t, len(leftovers), 0)
+}
+
+func TestPruneSessions(t *testing.T) {
+	stateDir := t.TempDir()
+	now := time.Now()
+	for name, age := range map[string]time.Duration{
+		"current.tar.gz": 48 * time.Hour,
+		"current.json":   48 * time.Hour,
+		"old.tar.gz":     48 * time.Hour,
+		"old.json":       48 * time.Hour,
+		"recent.tar.gz":  time.Hour,
+		"recent.json":    time.Hour,
+		"notes.txt":      48 * time.Hour,
+	} {
+		filename := filepath.Join(stateDir, name)
+		if err := ioutil.WriteFile(filename, nil, 0600); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.Chtimes(filename, now.Add(-age), now.Add(-age)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pruneSessions(stateDir, "current", now)
+
+	var names []string
+	files, _ := ioutil.ReadDir(stateDir)
+	for _, file := range files {
+		names = append(names, file.Name())
+	}
+	eq(t, names, []string{"current.json", "current.tar.gz", "notes.txt", "recent.json", "recent.tar.gz"})
+}
+
+func TestRetry_Delay(t *testing.T) {
+	r := Retry{Initial: time.Second, Max: 5 * time.Second}
+	eq(t, r.Delay(1), time.Second)
+	eq(t, r.Delay(2), 2*time.Second)
+	eq(t, r.Delay(3), 4*time.Second)
+	eq(t, r.Delay(4), 5*time.Second)
+}
+
+func TestRetry_DoStopsOnClientError(t *testing.T) {
+	calls := 0
+	err := Retry{Attempts: 5}.Do(func() error {
+		calls++
+		return &StatusError{StatusCode: http.StatusUnauthorized}
+	})
+	if err == nil {
+		t.Error("expected an error")
+	}
+	eq(t, calls, 1)
+}
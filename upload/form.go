@@ -0,0 +1,102 @@
+// Package upload packages build artifacts for transfer to the FeaturePeek API
+package upload
+
+import (
+	"io"
+	"io/ioutil"
+	"mime/multipart"
+)
+
+// ArtifactField is the form field name the API expects the archive under
+const ArtifactField = "artifacts"
+
+// ArtifactFilename is the filename sent with the archive part
+const ArtifactFilename = "artifacts.tar.gz"
+
+// Field is a plain key/value form field
+type Field struct {
+	Name  string
+	Value string
+}
+
+// Form is a multipart/form-data upload made of plain fields followed by a
+// tar.gz archive of a directory. The body is generated on demand, so it is
+// never held in memory as a whole.
+type Form struct {
+	Dir      string
+	Fields   []Field
+	boundary string
+}
+
+// NewForm creates a Form that will archive the contents of dir
+func NewForm(dir string) *Form {
+	return &Form{
+		Dir:      dir,
+		boundary: multipart.NewWriter(nil).Boundary(),
+	}
+}
+
+// AddField appends a plain form field; fields are written in the order added
+func (f *Form) AddField(name, value string) {
+	f.Fields = append(f.Fields, Field{Name: name, Value: value})
+}
+
+// ContentType returns the Content-Type header value, including the boundary
+func (f *Form) ContentType() string {
+	return "multipart/form-data; boundary=" + f.boundary
+}
+
+// WriteTo writes the complete multipart body to w
+func (f *Form) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	mw := multipart.NewWriter(cw)
+	if err := mw.SetBoundary(f.boundary); err != nil {
+		return cw.n, err
+	}
+
+	for _, field := range f.Fields {
+		if err := mw.WriteField(field.Name, field.Value); err != nil {
+			return cw.n, err
+		}
+	}
+
+	part, err := mw.CreateFormFile(ArtifactField, ArtifactFilename)
+	if err != nil {
+		return cw.n, err
+	}
+	if err = WriteArchive(part, f.Dir); err != nil {
+		return cw.n, err
+	}
+
+	err = mw.Close()
+	return cw.n, err
+}
+
+// Reader returns a stream of the multipart body. Archiving happens in a
+// separate goroutine as the reader is consumed; closing the reader early
+// aborts it.
+func (f *Form) Reader() io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		_, err := f.WriteTo(pw)
+		pw.CloseWithError(err)
+	}()
+	return pr
+}
+
+// Size computes the exact length of the multipart body by generating it once
+// and discarding the output. Use it when the server requires Content-Length.
+func (f *Form) Size() (int64, error) {
+	return f.WriteTo(ioutil.Discard)
+}
+
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,144 @@
+package upload
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestForm_WriteTo(t *testing.T) {
+	dir := makeAssetDir(t)
+	defer os.RemoveAll(dir)
+
+	form := NewForm(dir)
+	form.AddField("app", "main")
+	form.AddField("sha", "abc123")
+
+	body := &bytes.Buffer{}
+	n, err := form.WriteTo(body)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	eq(t, n, int64(body.Len()))
+
+	fields, entries := readForm(t, form.ContentType(), body)
+	eq(t, fields, map[string]string{"app": "main", "sha": "abc123"})
+	eq(t, entries, []string{"index.html", "static/", "static/app.js"})
+}
+
+func TestForm_Reader(t *testing.T) {
+	dir := makeAssetDir(t)
+	defer os.RemoveAll(dir)
+
+	form := NewForm(dir)
+	form.AddField("app", "main")
+
+	r := form.Reader()
+	defer r.Close()
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	size, err := form.Size()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	eq(t, size, int64(len(data)))
+
+	fields, entries := readForm(t, form.ContentType(), bytes.NewReader(data))
+	eq(t, fields, map[string]string{"app": "main"})
+	eq(t, entries, []string{"index.html", "static/", "static/app.js"})
+}
+
+func TestForm_ReaderMissingDir(t *testing.T) {
+	form := NewForm(filepath.Join(os.TempDir(), "peek-does-not-exist"))
+	r := form.Reader()
+	defer r.Close()
+	if _, err := ioutil.ReadAll(r); err == nil {
+		t.Error("expected an error reading a missing directory")
+	}
+}
+
+// Helper functions
+func makeAssetDir(t *testing.T) string {
+	t.Helper()
+	dir, err := ioutil.TempDir("", "peek-upload")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = os.MkdirAll(filepath.Join(dir, "static"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"index.html":    "<html></html>",
+		"static/app.js": "console.log('hi')",
+	}
+	for name, content := range files {
+		if err = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func readForm(t *testing.T, contentType string, body io.Reader) (map[string]string, []string) {
+	t.Helper()
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fields := map[string]string{}
+	var entries []string
+	mr := multipart.NewReader(body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		if part.FormName() != ArtifactField {
+			value, _ := ioutil.ReadAll(part)
+			fields[part.FormName()] = string(value)
+			continue
+		}
+
+		eq(t, part.FileName(), ArtifactFilename)
+		gz, err := gzip.NewReader(part)
+		if err != nil {
+			t.Fatal(err)
+		}
+		tr := tar.NewReader(gz)
+		for {
+			hdr, err := tr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			entries = append(entries, hdr.Name)
+		}
+	}
+	sort.Strings(entries)
+	return fields, entries
+}
+
+func eq(t *testing.T, got interface{}, expected interface{}) {
+	t.Helper()
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("expected: %v, got: %v", expected, got)
+	}
+}
@@ -0,0 +1,121 @@
+package upload
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"peek/manifest"
+)
+
+// IncrementalUploader deploys a build by content hash, sending only the files
+// the server does not already have.
+//
+// The protocol, relative to URL, is:
+//
+//	POST /manifests                JSON fields and file list; replies {"id": "...", "missing": ["<sha256>", ...]}
+//	PUT  /blobs/{sha256}           raw file contents
+//	POST /manifests/{id}/complete  replies like a single-request upload
+type IncrementalUploader struct {
+	Client *http.Client
+	URL    string
+	Header HeaderFunc
+	Retry  Retry
+}
+
+// Deployment is a manifest the server has accepted, along with the files it still needs
+type Deployment struct {
+	ID      string
+	Missing []manifest.Entry
+}
+
+// Start sends the manifest and returns the files the server asked for
+func (u *IncrementalUploader) Start(fields []Field, m manifest.Manifest) (*Deployment, error) {
+	payload := struct {
+		Fields map[string]string `json:"fields"`
+		Files  manifest.Manifest `json:"files"`
+	}{
+		Fields: map[string]string{},
+		Files:  m,
+	}
+	for _, field := range fields {
+		payload.Fields[field.Name] = field.Value
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	header := http.Header{}
+	header.Set("Content-Type", "application/json")
+
+	var body []byte
+	err = u.Retry.Do(func() (err error) {
+		_, body, err = doWith(u.Client, u.Header, "POST", u.URL+"/manifests", data, header)
+		return
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		ID      string   `json:"id"`
+		Missing []string `json:"missing"`
+	}
+	if err = json.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("unexpected response to manifest: %s", body)
+	}
+	if resp.ID == "" {
+		return nil, fmt.Errorf("deployment id missing from response: %s", body)
+	}
+
+	byHash := m.ByHash()
+	deployment := &Deployment{ID: resp.ID}
+	for _, hash := range resp.Missing {
+		entry, ok := byHash[hash]
+		if !ok {
+			return nil, fmt.Errorf("server requested unknown file %s", hash)
+		}
+		deployment.Missing = append(deployment.Missing, entry)
+	}
+	return deployment, nil
+}
+
+// SendMissing uploads each file the server asked for, reading them from dir
+func (u *IncrementalUploader) SendMissing(dir string, deployment *Deployment) error {
+	header := http.Header{}
+	header.Set("Content-Type", "application/octet-stream")
+
+	for _, entry := range deployment.Missing {
+		data, err := ioutil.ReadFile(filepath.Join(dir, filepath.FromSlash(entry.Path)))
+		if err != nil {
+			return err
+		}
+
+		reqURL := u.URL + "/blobs/" + url.PathEscape(entry.Hash)
+		err = u.Retry.Do(func() error {
+			_, _, err := doWith(u.Client, u.Header, "PUT", reqURL, data, header)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("uploading %s: %w", entry.Path, err)
+		}
+	}
+	return nil
+}
+
+// Complete finalizes the deployment once all missing files are uploaded
+func (u *IncrementalUploader) Complete(deployment *Deployment) (int, []byte, error) {
+	reqURL := u.URL + "/manifests/" + url.PathEscape(deployment.ID) + "/complete"
+
+	var status int
+	var body []byte
+	err := u.Retry.Do(func() (err error) {
+		status, body, err = doWith(u.Client, u.Header, "POST", reqURL, nil)
+		return
+	})
+	return status, body, err
+}
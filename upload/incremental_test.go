@@ -0,0 +1,84 @@
+package upload
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"peek/manifest"
+)
+
+func TestIncrementalUploader(t *testing.T) {
+	dir := makeAssetDir(t)
+	defer os.RemoveAll(dir)
+
+	m, err := manifest.Build(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var received manifest.Manifest
+	var fields map[string]string
+	blobs := map[string]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/peek/manifests":
+			var payload struct {
+				Fields map[string]string `json:"fields"`
+				Files  manifest.Manifest `json:"files"`
+			}
+			json.NewDecoder(r.Body).Decode(&payload)
+			received = payload.Files
+			fields = payload.Fields
+			// pretend only the JavaScript bundle changed
+			missing := []string{}
+			for _, f := range payload.Files {
+				if strings.HasSuffix(f.Path, ".js") {
+					missing = append(missing, f.Hash)
+				}
+			}
+			json.NewEncoder(w).Encode(map[string]interface{}{"id": "dep-1", "missing": missing})
+		case r.Method == "PUT" && strings.HasPrefix(r.URL.Path, "/peek/blobs/"):
+			data, _ := ioutil.ReadAll(r.Body)
+			blobs[strings.TrimPrefix(r.URL.Path, "/peek/blobs/")] = string(data)
+			w.WriteHeader(http.StatusNoContent)
+		case r.Method == "POST" && r.URL.Path == "/peek/manifests/dep-1/complete":
+			w.WriteHeader(http.StatusCreated)
+			w.Write([]byte("https://preview.example.com"))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	uploader := &IncrementalUploader{
+		URL:   server.URL + "/peek",
+		Retry: Retry{Attempts: 2, Initial: time.Millisecond},
+	}
+
+	deployment, err := uploader.Start([]Field{{Name: "app", Value: "main"}}, m)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	eq(t, received, m)
+	eq(t, fields, map[string]string{"app": "main"})
+	eq(t, len(deployment.Missing), 1)
+	eq(t, deployment.Missing[0].Path, "static/app.js")
+
+	if err = uploader.SendMissing(dir, deployment); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	eq(t, blobs, map[string]string{deployment.Missing[0].Hash: "console.log('hi')"})
+
+	status, body, err := uploader.Complete(deployment)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	eq(t, status, http.StatusCreated)
+	eq(t, string(body), "https://preview.example.com")
+}
@@ -0,0 +1,57 @@
+package upload
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+)
+
+// HeaderFunc returns the headers to send with a request. It is called for
+// every request, so a long upload picks up a refreshed access token.
+type HeaderFunc func() (http.Header, error)
+
+// doWith sends a request with the headers from base, which may be nil,
+// followed by the given headers
+func doWith(client *http.Client, base HeaderFunc, method, reqURL string, body []byte, headers ...http.Header) (int, []byte, error) {
+	if base != nil {
+		header, err := base()
+		if err != nil {
+			return 0, nil, err
+		}
+		headers = append([]http.Header{header}, headers...)
+	}
+	return do(client, method, reqURL, body, headers...)
+}
+
+// do sends a request with the given headers applied in order and returns the
+// status and body. Non-2xx responses are reported as a *StatusError.
+func do(client *http.Client, method, reqURL string, body []byte, headers ...http.Header) (int, []byte, error) {
+	request, err := http.NewRequest(method, reqURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, nil, err
+	}
+	for _, header := range headers {
+		for k, v := range header {
+			request.Header[k] = v
+		}
+	}
+
+	if client == nil {
+		client = http.DefaultClient
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return 0, nil, err
+	}
+	defer response.Body.Close()
+
+	resBody, err := ioutil.ReadAll(response.Body)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return response.StatusCode, resBody, &StatusError{StatusCode: response.StatusCode, Body: resBody}
+	}
+	return response.StatusCode, resBody, nil
+}
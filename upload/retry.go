@@ -0,0 +1,80 @@
+package upload
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Retry describes how many times a request is attempted and how long to wait between attempts
+type Retry struct {
+	Attempts int
+	Initial  time.Duration
+	Max      time.Duration
+}
+
+// DefaultRetry backs off from one second up to thirty over six attempts
+var DefaultRetry = Retry{
+	Attempts: 6,
+	Initial:  time.Second,
+	Max:      30 * time.Second,
+}
+
+// Delay returns the wait before the given retry (1 for the first retry), doubling each time up to Max
+func (r Retry) Delay(retry int) time.Duration {
+	d := r.Initial
+	for i := 1; i < retry; i++ {
+		d *= 2
+		if r.Max > 0 && d >= r.Max {
+			return r.Max
+		}
+	}
+	return d
+}
+
+// StatusError is returned for a response with an unexpected status code
+type StatusError struct {
+	StatusCode int
+	Body       []byte
+}
+
+func (e *StatusError) Error() string {
+	if len(e.Body) == 0 {
+		return fmt.Sprintf("request failed with status %d", e.StatusCode)
+	}
+	return fmt.Sprintf("request failed with status %d - %s", e.StatusCode, e.Body)
+}
+
+// Do runs fn until it succeeds, returns a non-retryable error, or runs out of attempts
+func (r Retry) Do(fn func() error) error {
+	attempts := r.Attempts
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if attempt > 1 {
+			time.Sleep(r.Delay(attempt - 1))
+		}
+		if err = fn(); err == nil || !retryable(err) {
+			return err
+		}
+	}
+	return err
+}
+
+// retryable reports whether err is a transient network failure or a server-side error
+func retryable(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode >= http.StatusInternalServerError ||
+			statusErr.StatusCode == http.StatusTooManyRequests ||
+			statusErr.StatusCode == http.StatusRequestTimeout
+	}
+	// http.Client wraps all transport failures in *url.Error, which is a net.Error
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}